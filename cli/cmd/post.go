@@ -44,13 +44,17 @@ Will post "hello world!" to the server`,
 			if err := viper.BindPFlag("token", cmd.Flags().Lookup("token")); err != nil {
 				return err
 			}
-			return viper.BindPFlag("languages", cmd.Flags().Lookup("languages"))
+			if err := viper.BindPFlag("languages", cmd.Flags().Lookup("languages")); err != nil {
+				return err
+			}
+			return viper.BindPFlag("message", cmd.Flags().Lookup("message"))
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			files := viper.GetStringSlice("files")
 			documentID := viper.GetString("document")
 			token := viper.GetString("token")
 			languages := viper.GetStringSlice("languages")
+			message := viper.GetString("message")
 
 			var (
 				readers []io.Reader
@@ -104,18 +108,28 @@ Will post "hello world!" to the server`,
 				if file, ok := r.(*os.File); ok {
 					fileName = file.Name()
 				}
-				r = ezhttp.NewHeaderReader(readers[0], http.Header{
+				header := http.Header{
 					ezhttp.HeaderContentType: []string{
 						mime.FormatMediaType(contentType, map[string]string{
 							"filename": fileName,
 						}),
 					},
-				})
+				}
+				if message != "" {
+					header.Set(ezhttp.HeaderMessage, message)
+				}
+				r = ezhttp.NewHeaderReader(readers[0], header)
 
 			} else {
 				buff := new(bytes.Buffer)
 				mpw := multipart.NewWriter(buff)
 
+				if message != "" {
+					if err := mpw.WriteField("message", message); err != nil {
+						return fmt.Errorf("failed to write message field")
+					}
+				}
+
 				for i, rr := range readers {
 					contentType := ezhttp.DefaultContentTyp
 					if len(languages) > i {
@@ -208,6 +222,7 @@ Will post "hello world!" to the server`,
 	cmd.Flags().StringP("document", "d", "", "The document to update")
 	cmd.Flags().StringP("token", "t", "", "The token for the document to update")
 	cmd.Flags().StringP("languages", "l", "", "The language of the documents")
+	cmd.Flags().StringP("message", "m", "", "The message of the document version")
 
 	if err := cmd.RegisterFlagCompletionFunc("files", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return nil, cobra.ShellCompDirectiveDefault
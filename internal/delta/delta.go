@@ -0,0 +1,96 @@
+package delta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/topi314/gobin/v2/internal/diff"
+)
+
+const (
+	formatVersion byte = 1
+
+	opCopy   byte = 1
+	opInsert byte = 2
+)
+
+var ErrInvalidDelta = errors.New("invalid delta")
+
+// Encode creates a delta which turns base into target when applied with Apply.
+// The delta is computed line by line and copies unchanged lines from base.
+func Encode(base []byte, target []byte) []byte {
+	baseLines := diff.SplitLines(string(base))
+	targetLines := diff.SplitLines(string(target))
+
+	baseOffsets := make([]int, len(baseLines)+1)
+	for i, line := range baseLines {
+		baseOffsets[i+1] = baseOffsets[i] + len(line)
+	}
+	targetOffsets := make([]int, len(targetLines)+1)
+	for i, line := range targetLines {
+		targetOffsets[i+1] = targetOffsets[i] + len(line)
+	}
+
+	buf := []byte{formatVersion}
+	for _, edit := range diff.Diff(baseLines, targetLines) {
+		switch edit.Op {
+		case diff.OpEqual:
+			buf = append(buf, opCopy)
+			buf = binary.AppendUvarint(buf, uint64(baseOffsets[edit.AStart]))
+			buf = binary.AppendUvarint(buf, uint64(baseOffsets[edit.AEnd]-baseOffsets[edit.AStart]))
+		case diff.OpInsert:
+			data := target[targetOffsets[edit.BStart]:targetOffsets[edit.BEnd]]
+			buf = append(buf, opInsert)
+			buf = binary.AppendUvarint(buf, uint64(len(data)))
+			buf = append(buf, data...)
+		}
+	}
+	return buf
+}
+
+// Apply reconstructs the target from base and a delta created by Encode.
+func Apply(base []byte, delta []byte) ([]byte, error) {
+	if len(delta) == 0 || delta[0] != formatVersion {
+		return nil, fmt.Errorf("%w: unknown format", ErrInvalidDelta)
+	}
+
+	r := bytes.NewReader(delta[1:])
+	var out []byte
+	for {
+		op, err := r.ReadByte()
+		if err != nil {
+			return out, nil
+		}
+
+		switch op {
+		case opCopy:
+			offset, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			length, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			if offset+length > uint64(len(base)) {
+				return nil, fmt.Errorf("%w: copy out of range", ErrInvalidDelta)
+			}
+			out = append(out, base[offset:offset+length]...)
+		case opInsert:
+			length, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidDelta, err)
+			}
+			if length > uint64(r.Len()) {
+				return nil, fmt.Errorf("%w: insert out of range", ErrInvalidDelta)
+			}
+			data := make([]byte, length)
+			_, _ = r.Read(data)
+			out = append(out, data...)
+		default:
+			return nil, fmt.Errorf("%w: unknown op %d", ErrInvalidDelta, op)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package delta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEncodeApply(t *testing.T) {
+	var manyBase, manyTarget strings.Builder
+	for i := range 3000 {
+		_, _ = fmt.Fprintf(&manyBase, "line %d\n", i)
+		_, _ = fmt.Fprintf(&manyTarget, "other %d\n", i)
+	}
+
+	tests := []struct {
+		name   string
+		base   string
+		target string
+	}{
+		{name: "empty", base: "", target: ""},
+		{name: "empty base", base: "", target: "a\nb\n"},
+		{name: "empty target", base: "a\nb\n", target: ""},
+		{name: "equal", base: "a\nb\nc\n", target: "a\nb\nc\n"},
+		{name: "insert", base: "a\nc\n", target: "a\nb\nc\n"},
+		{name: "delete", base: "a\nb\nc\n", target: "a\nc\n"},
+		{name: "replace", base: "a\nb\nc\n", target: "a\nx\nc\n"},
+		{name: "move", base: "a\nb\nc\n", target: "c\na\nb\n"},
+		{name: "missing trailing newline in base", base: "a\nb", target: "a\nb\n"},
+		{name: "missing trailing newline in target", base: "a\nb\n", target: "a\nb"},
+		{name: "missing trailing newline in both", base: "a\nb", target: "a\nc"},
+		{name: "no newline at all", base: "abc", target: "abd"},
+		{name: "blank lines", base: "\n\n\n", target: "\n\na\n\n"},
+		{name: "crlf", base: "a\r\nb\r\n", target: "a\r\nc\r\n"},
+		{name: "binary", base: "\x00\x01\n\xff", target: "\x00\x02\n\xff"},
+		{name: "more changes than the max edit distance", base: manyBase.String(), target: manyTarget.String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta := Encode([]byte(tt.base), []byte(tt.target))
+			got, err := Apply([]byte(tt.base), delta)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !bytes.Equal(got, []byte(tt.target)) {
+				t.Errorf("Apply() = %q, want %q", got, tt.target)
+			}
+		})
+	}
+}
+
+func TestEncodeCopiesUnchangedLines(t *testing.T) {
+	base := []byte(strings.Repeat("an unchanged line\n", 100))
+	target := append(bytes.Clone(base), "a new line\n"...)
+
+	delta := Encode(base, target)
+	if len(delta) >= len(target) {
+		t.Errorf("Encode() returned %d bytes, want less than the %d bytes of the target", len(delta), len(target))
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		base  string
+		delta []byte
+	}{
+		{name: "empty", delta: nil},
+		{name: "unknown format", delta: []byte{formatVersion + 1}},
+		{name: "unknown op", delta: []byte{formatVersion, 3}},
+		{name: "truncated copy", delta: []byte{formatVersion, opCopy, 0}},
+		{name: "copy out of range", base: "abc", delta: []byte{formatVersion, opCopy, 1, 3}},
+		{name: "truncated insert", delta: []byte{formatVersion, opInsert}},
+		{name: "insert out of range", delta: []byte{formatVersion, opInsert, 4, 'a'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply([]byte(tt.base), tt.delta); !errors.Is(err, ErrInvalidDelta) {
+				t.Errorf("Apply() error = %v, want %v", err, ErrInvalidDelta)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxEditDistance limits the work done by the myers algorithm.
+// Inputs which differ in more elements are diffed as a whole replacement of the changed region.
+const maxEditDistance = 1024
+
+type Op int
+
+const (
+	OpEqual Op = iota
+	OpInsert
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpEqual:
+		return "equal"
+	case OpInsert:
+		return "insert"
+	case OpDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Edit describes a run of elements which are equal, inserted or deleted.
+// A[AStart:AEnd] are the affected elements of a and B[BStart:BEnd] of b.
+// For OpInsert the range in a and for OpDelete the range in b are empty.
+type Edit struct {
+	Op     Op
+	AStart int
+	AEnd   int
+	BStart int
+	BEnd   int
+}
+
+// SplitLines splits s into lines, each line keeps its trailing newline.
+func SplitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// SplitWords splits s into words, runs of whitespace and single punctuation characters.
+// Joining the returned elements gives s again.
+func SplitWords(s string) []string {
+	var words []string
+	for s != "" {
+		r, end := utf8.DecodeRuneInString(s)
+		if word := isWordRune(r); word || unicode.IsSpace(r) {
+			for end < len(s) {
+				next, size := utf8.DecodeRuneInString(s[end:])
+				if word && !isWordRune(next) || !word && !unicode.IsSpace(next) {
+					break
+				}
+				end += size
+			}
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+	return words
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Diff computes the edits which turn a into b using the myers diff algorithm.
+func Diff[T comparable](a []T, b []T) []Edit {
+	var prefix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	var suffix int
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for range prefix {
+		ops = append(ops, OpEqual)
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for range suffix {
+		ops = append(ops, OpEqual)
+	}
+
+	var (
+		edits []Edit
+		x, y  int
+	)
+	for _, op := range ops {
+		if len(edits) == 0 || edits[len(edits)-1].Op != op {
+			edits = append(edits, Edit{Op: op, AStart: x, AEnd: x, BStart: y, BEnd: y})
+		}
+		edit := &edits[len(edits)-1]
+		switch op {
+		case OpEqual:
+			x++
+			y++
+		case OpDelete:
+			x++
+		case OpInsert:
+			y++
+		}
+		edit.AEnd = x
+		edit.BEnd = y
+	}
+	return edits
+}
+
+func myers[T comparable](a []T, b []T) []Op {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	maxD := min(n+m, maxEditDistance)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+				return backtrack(trace, n, m)
+			}
+		}
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+	}
+
+	// too many differences, replace everything
+	ops := make([]Op, 0, n+m)
+	for range n {
+		ops = append(ops, OpDelete)
+	}
+	for range m {
+		ops = append(ops, OpInsert)
+	}
+	return ops
+}
+
+func backtrack(trace [][]int, n int, m int) []Op {
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		prev := trace[d-1]
+		k := x - y
+
+		var prevK int
+		insert := k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1])
+		if insert {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+
+		startX, startY := prevX+1, prevY
+		if insert {
+			startX, startY = prevX, prevY+1
+		}
+		for x > startX && y > startY {
+			ops = append(ops, OpEqual)
+			x--
+			y--
+		}
+		if insert {
+			ops = append(ops, OpInsert)
+		} else {
+			ops = append(ops, OpDelete)
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, OpEqual)
+		x--
+		y--
+	}
+
+	slices.Reverse(ops)
+	return ops
+}
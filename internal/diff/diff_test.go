@@ -0,0 +1,194 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "empty", s: "", want: nil},
+		{name: "single line", s: "a\n", want: []string{"a\n"}},
+		{name: "missing trailing newline", s: "a\nb", want: []string{"a\n", "b"}},
+		{name: "blank lines", s: "\n\n", want: []string{"\n", "\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitLines(tt.s); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{name: "empty", s: "", want: nil},
+		{name: "words and spaces", s: "foo  bar", want: []string{"foo", "  ", "bar"}},
+		{name: "punctuation", s: "a.b(c)", want: []string{"a", ".", "b", "(", "c", ")"}},
+		{name: "identifiers", s: "foo_bar1 := 2", want: []string{"foo_bar1", " ", ":", "=", " ", "2"}},
+		{name: "unicode", s: "grüße, welt", want: []string{"grüße", ",", " ", "welt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitWords(tt.s); !slices.Equal(got, tt.want) {
+				t.Errorf("SplitWords() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// diffTests are shared by the tests of Diff and the unified output.
+var diffTests = []struct {
+	name string
+	a    string
+	b    string
+}{
+	{name: "empty", a: "", b: ""},
+	{name: "equal", a: "a\nb\nc\n", b: "a\nb\nc\n"},
+	{name: "empty a", a: "", b: "a\nb\n"},
+	{name: "empty b", a: "a\nb\n", b: ""},
+	{name: "insert", a: "a\nc\n", b: "a\nb\nc\n"},
+	{name: "delete", a: "a\nb\nc\n", b: "a\nc\n"},
+	{name: "replace", a: "a\nb\nc\n", b: "a\nx\nc\n"},
+	{name: "missing trailing newline in a", a: "a\nb", b: "a\nb\n"},
+	{name: "missing trailing newline in b", a: "a\nb\n", b: "a\nb"},
+	{name: "missing trailing newline in both", a: "a\nb", b: "a\nc"},
+	{name: "separate hunks", a: numberedLines(1, 20), b: strings.Replace(strings.Replace(numberedLines(1, 20), "2\n", "two\n", 1), "19\n", "nineteen\n", 1)},
+	{name: "merged hunks", a: numberedLines(1, 10), b: strings.Replace(strings.Replace(numberedLines(1, 10), "2\n", "two\n", 1), "8\n", "eight\n", 1)},
+	{name: "more changes than the max edit distance", a: numberedLines(0, 3000), b: numberedLines(3000, 6000)},
+}
+
+func numberedLines(from int, to int) string {
+	var sb strings.Builder
+	for i := from; i < to; i++ {
+		_, _ = fmt.Fprintf(&sb, "%d\n", i)
+	}
+	return sb.String()
+}
+
+func TestDiff(t *testing.T) {
+	for _, tt := range diffTests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := SplitLines(tt.a), SplitLines(tt.b)
+
+			var gotA, gotB []string
+			var x, y int
+			for _, edit := range Diff(a, b) {
+				if edit.AStart != x || edit.BStart != y {
+					t.Fatalf("edit %+v does not continue at %d, %d", edit, x, y)
+				}
+				switch edit.Op {
+				case OpEqual:
+					if !slices.Equal(a[edit.AStart:edit.AEnd], b[edit.BStart:edit.BEnd]) {
+						t.Fatalf("equal edit %+v covers different lines", edit)
+					}
+					gotA = append(gotA, a[edit.AStart:edit.AEnd]...)
+					gotB = append(gotB, b[edit.BStart:edit.BEnd]...)
+				case OpDelete:
+					if edit.BStart != edit.BEnd {
+						t.Fatalf("delete edit %+v covers lines of b", edit)
+					}
+					gotA = append(gotA, a[edit.AStart:edit.AEnd]...)
+				case OpInsert:
+					if edit.AStart != edit.AEnd {
+						t.Fatalf("insert edit %+v covers lines of a", edit)
+					}
+					gotB = append(gotB, b[edit.BStart:edit.BEnd]...)
+				}
+				x, y = edit.AEnd, edit.BEnd
+			}
+			if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+				t.Errorf("edits do not cover a and b")
+			}
+		})
+	}
+}
+
+func TestLineHunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		context int
+		want    []string
+	}{
+		{name: "equal", a: "a\nb\n", b: "a\nb\n", context: 3, want: nil},
+		{name: "empty a", a: "", b: "a\nb\n", context: 3, want: []string{"@@ -0,0 +1,2 @@"}},
+		{name: "empty b", a: "a\n", b: "", context: 3, want: []string{"@@ -1 +0,0 @@"}},
+		{name: "context", a: numberedLines(1, 10), b: strings.Replace(numberedLines(1, 10), "5\n", "five\n", 1), context: 1, want: []string{"@@ -4,3 +4,3 @@"}},
+		{name: "separate hunks", a: numberedLines(1, 20), b: strings.Replace(strings.Replace(numberedLines(1, 20), "2\n", "two\n", 1), "19\n", "nineteen\n", 1), context: 3, want: []string{"@@ -1,5 +1,5 @@", "@@ -16,4 +16,4 @@"}},
+		{name: "merged hunks", a: numberedLines(1, 10), b: strings.Replace(strings.Replace(numberedLines(1, 10), "2\n", "two\n", 1), "8\n", "eight\n", 1), context: 3, want: []string{"@@ -1,9 +1,9 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, hunk := range LineHunks(SplitLines(tt.a), SplitLines(tt.b), tt.context) {
+				got = append(got, hunk.Header())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LineHunks() headers = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWriteUnifiedPatch checks that the unified output turns a into b when applied with patch.
+func TestWriteUnifiedPatch(t *testing.T) {
+	patch, err := exec.LookPath("patch")
+	if err != nil {
+		t.Skip("patch is not installed")
+	}
+
+	for _, tt := range diffTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "file")
+			if err := os.WriteFile(file, []byte(tt.a), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			hunks := LineHunks(SplitLines(tt.a), SplitLines(tt.b), 3)
+			if len(hunks) == 0 {
+				// patch rejects an empty diff
+				if tt.a != tt.b {
+					t.Fatal("LineHunks() returned no hunks for different inputs")
+				}
+				return
+			}
+
+			buf := bytes.NewBufferString("--- a/file\n+++ b/file\n")
+			if err := WriteUnified(buf, hunks); err != nil {
+				t.Fatalf("WriteUnified() error = %v", err)
+			}
+
+			cmd := exec.Command(patch, "--batch", "--forward", "--no-backup-if-mismatch", file)
+			cmd.Stdin = buf
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("patch failed: %v\n%s", err, output)
+			}
+
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.b {
+				t.Errorf("patched file = %q, want %q", got, tt.b)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Line is a single line of a hunk, Text keeps its trailing newline if it has one.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Hunk is a group of changed lines with the surrounding context lines.
+// The start lines are numbered like in a unified diff header, so they are 1 based
+// and point to the line before the hunk if it does not contain any lines of that side.
+type Hunk struct {
+	AStart int
+	ALines int
+	BStart int
+	BLines int
+	Lines  []Line
+}
+
+// Header returns the unified diff header of the hunk, like "@@ -1,3 +1,4 @@".
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", unifiedRange(h.AStart, h.ALines), unifiedRange(h.BStart, h.BLines))
+}
+
+func unifiedRange(start int, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(lines)
+}
+
+// LineHunks diffs the lines of a and b and groups the changes into hunks with up to context unchanged lines around them.
+// Changes which are separated by at most 2*context unchanged lines end up in the same hunk.
+func LineHunks(a []string, b []string, context int) []Hunk {
+	edits := Diff(a, b)
+
+	var hunks []Hunk
+	for i := 0; i < len(edits); i++ {
+		if edits[i].Op == OpEqual {
+			continue
+		}
+
+		first, last := i, i
+		for last+1 < len(edits) {
+			next := edits[last+1]
+			if next.Op != OpEqual {
+				last++
+				continue
+			}
+			if last+2 < len(edits) && next.AEnd-next.AStart <= 2*context {
+				last += 2
+				continue
+			}
+			break
+		}
+		i = last
+
+		hunks = append(hunks, newHunk(a, b, edits, first, last, context))
+	}
+	return hunks
+}
+
+func newHunk(a []string, b []string, edits []Edit, first int, last int, context int) Hunk {
+	var before, after int
+	if first > 0 {
+		before = min(context, edits[first-1].AEnd-edits[first-1].AStart)
+	}
+	if last+1 < len(edits) {
+		after = min(context, edits[last+1].AEnd-edits[last+1].AStart)
+	}
+
+	aStart, aEnd := edits[first].AStart-before, edits[last].AEnd+after
+	bStart, bEnd := edits[first].BStart-before, edits[last].BEnd+after
+
+	var lines []Line
+	for _, text := range a[aStart:edits[first].AStart] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+	for _, edit := range edits[first : last+1] {
+		switch edit.Op {
+		case OpEqual, OpDelete:
+			for _, text := range a[edit.AStart:edit.AEnd] {
+				lines = append(lines, Line{Op: edit.Op, Text: text})
+			}
+		case OpInsert:
+			for _, text := range b[edit.BStart:edit.BEnd] {
+				lines = append(lines, Line{Op: OpInsert, Text: text})
+			}
+		}
+	}
+	for _, text := range a[edits[last].AEnd:aEnd] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+
+	hunk := Hunk{
+		AStart: aStart + 1,
+		ALines: aEnd - aStart,
+		BStart: bStart + 1,
+		BLines: bEnd - bStart,
+		Lines:  lines,
+	}
+	if hunk.ALines == 0 {
+		hunk.AStart--
+	}
+	if hunk.BLines == 0 {
+		hunk.BStart--
+	}
+	return hunk
+}
+
+// WriteUnified writes the hunks in the unified diff format without the file header.
+func WriteUnified(w io.Writer, hunks []Hunk) error {
+	for _, hunk := range hunks {
+		if _, err := io.WriteString(w, hunk.Header()+"\n"); err != nil {
+			return err
+		}
+		for _, line := range hunk.Lines {
+			prefix := " "
+			switch line.Op {
+			case OpInsert:
+				prefix = "+"
+			case OpDelete:
+				prefix = "-"
+			}
+
+			text := prefix + line.Text
+			if !strings.HasSuffix(line.Text, "\n") {
+				text += "\n\\ No newline at end of file\n"
+			}
+			if _, err := io.WriteString(w, text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -22,6 +22,9 @@ const (
 	HeaderRateLimitReset     = "X-RateLimit-Reset"
 	HeaderRetryAfter         = "Retry-After"
 	HeaderCacheControl       = "Cache-Control"
+	HeaderDigest             = "Digest"
+	HeaderMessage            = "Message"
+	HeaderAuthor             = "Author"
 )
 
 const (
@@ -32,6 +35,9 @@ const (
 	ContentTypeSVG    = "image/svg+xml"
 	ContentTypePNG    = "image/png"
 	ContentTypeJSON   = "application/json"
+	ContentTypeDiff   = "text/x-diff; charset=UTF-8"
+
+	MediaTypeDiff = "text/x-diff"
 )
 
 type ErrorResponse struct {
@@ -62,6 +62,14 @@ func Forbidden(err error) error {
 	return New(err, http.StatusForbidden)
 }
 
+func Conflict(err error) error {
+	return New(err, http.StatusConflict)
+}
+
+func Gone(err error) error {
+	return New(err, http.StatusGone)
+}
+
 func TooManyRequests(err error) error {
 	return New(err, http.StatusTooManyRequests)
 }
@@ -0,0 +1,112 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// NewDirectorySource reads documents from a directory. Every file is a document with its name without extension as key,
+// every subdirectory is a document with the directory name as key and its files as document files.
+// Hidden files and directories are ignored.
+func NewDirectorySource(dir string) Source {
+	return &directorySource{
+		dir: dir,
+	}
+}
+
+type directorySource struct {
+	dir string
+}
+
+func (s *directorySource) Read(_ context.Context, fn func(document database.BackupDocument) error) error {
+	entries, err := readDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(s.dir, entry.Name())
+		var document database.BackupDocument
+		if entry.IsDir() {
+			document, err = readDirectoryDocument(entry.Name(), path)
+		} else {
+			var file database.BackupFile
+			var modTime time.Time
+			file, modTime, err = readFile(path)
+			document = newDocument(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), modTime, file)
+		}
+		if err != nil {
+			return err
+		}
+		if len(document.Versions[0].Files) == 0 {
+			continue
+		}
+		if err = fn(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDirectoryDocument(key string, dir string) (database.BackupDocument, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return database.BackupDocument{}, err
+	}
+
+	var (
+		files     []database.BackupFile
+		createdAt time.Time
+	)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		file, modTime, err := readFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return database.BackupDocument{}, err
+		}
+		files = append(files, file)
+		if modTime.After(createdAt) {
+			createdAt = modTime
+		}
+	}
+	return newDocument(key, createdAt, files...), nil
+}
+
+// readDir returns the regular files and directories of dir sorted by name without hidden ones.
+func readDir(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	entries = slices.DeleteFunc(entries, func(entry os.DirEntry) bool {
+		return strings.HasPrefix(entry.Name(), ".") || !entry.IsDir() && !entry.Type().IsRegular()
+	})
+	slices.SortFunc(entries, func(a os.DirEntry, b os.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func readFile(path string) (database.BackupFile, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return database.BackupFile{}, time.Time{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return database.BackupFile{}, time.Time{}, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	return database.BackupFile{
+		Name:    filepath.Base(path),
+		Content: string(content),
+	}, info.ModTime(), nil
+}
@@ -0,0 +1,59 @@
+package importer
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// NewHasteFileSource reads the file store of haste-server, which saves every document in a file named after the md5 hash of its key.
+// As the keys can't be recovered from the hashes, they have to be provided. Files without a known key are imported with their hash as key.
+func NewHasteFileSource(dir string, keys []string) Source {
+	hashes := make(map[string]string, len(keys))
+	for _, key := range keys {
+		sum := md5.Sum([]byte(key))
+		hashes[hex.EncodeToString(sum[:])] = key
+	}
+	return &hasteFileSource{
+		dir:    dir,
+		hashes: hashes,
+	}
+}
+
+type hasteFileSource struct {
+	dir    string
+	hashes map[string]string
+}
+
+func (s *hasteFileSource) Read(ctx context.Context, fn func(document database.BackupDocument) error) error {
+	entries, err := readDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		key, ok := s.hashes[entry.Name()]
+		if !ok {
+			slog.WarnContext(ctx, "Unknown key of haste-server document, importing it with its hash as key", slog.String("hash", entry.Name()))
+			key = entry.Name()
+		}
+
+		file, modTime, err := readFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		file.Name = "untitled"
+		if err = fn(newDocument(key, modTime, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
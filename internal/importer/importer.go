@@ -0,0 +1,169 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/topi314/chroma/v2/lexers"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// Source reads the documents of another paste service.
+type Source interface {
+	// Read calls fn for every document in the same order every time, so an import can be resumed.
+	Read(ctx context.Context, fn func(document database.BackupDocument) error) error
+}
+
+type Config struct {
+	// DryRun only reports what would be imported without changing the database.
+	DryRun bool
+	// ResumeFile records the keys of all documents which have been handled.
+	// Keys it already contains are skipped, so an interrupted import can continue where it stopped.
+	ResumeFile string
+	// ReservedSlugs are keys which can't be used by documents, as they would be hidden behind a route.
+	ReservedSlugs []string
+}
+
+type Stats struct {
+	Imported int
+	Existing int
+	Resumed  int
+	Reserved int
+}
+
+// Import creates a document with the original key for every document of the source.
+// Documents whose key is already used or reserved are skipped.
+func Import(ctx context.Context, store database.Store, source Source, cfg Config) (Stats, error) {
+	var stats Stats
+
+	done, err := readResumeFile(cfg.ResumeFile)
+	if err != nil {
+		return stats, err
+	}
+
+	var resumeFile *os.File
+	if cfg.ResumeFile != "" && !cfg.DryRun {
+		resumeFile, err = os.OpenFile(cfg.ResumeFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return stats, fmt.Errorf("failed to open resume file: %w", err)
+		}
+		defer resumeFile.Close()
+	}
+
+	err = source.Read(ctx, func(document database.BackupDocument) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := done[document.ID]; ok {
+			stats.Resumed++
+			return nil
+		}
+
+		if slices.ContainsFunc(cfg.ReservedSlugs, func(reserved string) bool {
+			return strings.EqualFold(reserved, document.ID)
+		}) {
+			slog.Info("Document key is reserved", slog.String("key", document.ID))
+			stats.Reserved++
+			return nil
+		}
+
+		if cfg.DryRun {
+			available, err := store.SlugAvailable(ctx, document.ID)
+			if err != nil {
+				return fmt.Errorf("failed to check document %s: %w", document.ID, err)
+			}
+			if !available {
+				slog.Info("Document already exists", slog.String("key", document.ID))
+				stats.Existing++
+				return nil
+			}
+			slog.Debug("Would import document", slog.String("key", document.ID), slog.Int("files", len(document.Versions[0].Files)))
+			stats.Imported++
+			return nil
+		}
+
+		if err := store.ImportDocument(ctx, document); err != nil {
+			if !errors.Is(err, database.ErrSlugTaken) {
+				return err
+			}
+			slog.Info("Document already exists", slog.String("key", document.ID))
+			stats.Existing++
+		} else {
+			slog.Debug("Imported document", slog.String("key", document.ID))
+			stats.Imported++
+		}
+
+		if resumeFile != nil {
+			if _, err := resumeFile.WriteString(document.ID + "\n"); err != nil {
+				return fmt.Errorf("failed to write resume file: %w", err)
+			}
+		}
+		return nil
+	})
+	return stats, err
+}
+
+func readResumeFile(path string) (map[string]struct{}, error) {
+	done := make(map[string]struct{})
+	if path == "" {
+		return done, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return done, nil
+		}
+		return nil, fmt.Errorf("failed to open resume file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			done[key] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read resume file: %w", err)
+	}
+	return done, nil
+}
+
+// newDocument returns a document with a single version created at createdAt.
+func newDocument(key string, createdAt time.Time, files ...database.BackupFile) database.BackupDocument {
+	for i := range files {
+		if files[i].Language == "" {
+			files[i].Language = detectLanguage(files[i].Name, files[i].Content)
+		}
+	}
+	return database.BackupDocument{
+		ID:        key,
+		CreatedAt: createdAt,
+		Versions: []database.BackupVersion{
+			{
+				Version: createdAt.UnixMilli(),
+				Files:   files,
+			},
+		},
+	}
+}
+
+func detectLanguage(fileName string, content string) string {
+	lexer := lexers.Match(fileName)
+	if lexer == nil && len(content) > 0 {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		return "plaintext"
+	}
+	return lexer.Config().Name
+}
@@ -0,0 +1,450 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// redis rdb opcodes
+const (
+	rdbOpSlotInfo     = 0xF4
+	rdbOpFunction2    = 0xF5
+	rdbOpFunction     = 0xF6
+	rdbOpModuleAux    = 0xF7
+	rdbOpIdle         = 0xF8
+	rdbOpFreq         = 0xF9
+	rdbOpAux          = 0xFA
+	rdbOpResizeDB     = 0xFB
+	rdbOpExpireTimeMs = 0xFC
+	rdbOpExpireTime   = 0xFD
+	rdbOpSelectDB     = 0xFE
+	rdbOpEOF          = 0xFF
+)
+
+// redis rdb value types
+const (
+	rdbTypeString         = 0
+	rdbTypeList           = 1
+	rdbTypeSet            = 2
+	rdbTypeZSet           = 3
+	rdbTypeHash           = 4
+	rdbTypeZSet2          = 5
+	rdbTypeHashZipmap     = 9
+	rdbTypeListZiplist    = 10
+	rdbTypeSetIntset      = 11
+	rdbTypeZSetZiplist    = 12
+	rdbTypeHashZiplist    = 13
+	rdbTypeListQuicklist  = 14
+	rdbTypeHashListpack   = 16
+	rdbTypeZSetListpack   = 17
+	rdbTypeListQuicklist2 = 18
+	rdbTypeSetListpack    = 20
+)
+
+// redis rdb special string encodings
+const (
+	rdbEncodingInt8  = 0
+	rdbEncodingInt16 = 1
+	rdbEncodingInt32 = 2
+	rdbEncodingLZF   = 3
+)
+
+// rdbMaxVersion is the newest rdb version which can be read.
+const rdbMaxVersion = 12
+
+// NewRedisDumpSource reads a redis rdb dump as written by the redis store of haste-server, which saves every document as a string value with its key.
+// Values of other types are ignored, expiration times are kept.
+func NewRedisDumpSource(path string) Source {
+	return &redisDumpSource{
+		path: path,
+	}
+}
+
+type redisDumpSource struct {
+	path string
+}
+
+func (s *redisDumpSource) Read(ctx context.Context, fn func(document database.BackupDocument) error) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open redis dump: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open redis dump: %w", err)
+	}
+
+	r := &rdbReader{r: bufio.NewReader(file)}
+	if err = r.readHeader(); err != nil {
+		return err
+	}
+
+	var (
+		now       = time.Now()
+		expiresAt *time.Time
+		skipped   int
+	)
+	for {
+		opcode, err := r.readByte()
+		if err != nil {
+			return err
+		}
+
+		switch opcode {
+		case rdbOpEOF:
+			if skipped > 0 {
+				slog.InfoContext(ctx, "Skipped redis values which are no documents", slog.Int("count", skipped))
+			}
+			return nil
+		case rdbOpSelectDB, rdbOpIdle:
+			_, err = r.readLength()
+		case rdbOpResizeDB:
+			if _, err = r.readLength(); err == nil {
+				_, err = r.readLength()
+			}
+		case rdbOpSlotInfo:
+			for i := 0; i < 3 && err == nil; i++ {
+				_, err = r.readLength()
+			}
+		case rdbOpAux:
+			if _, err = r.readString(); err == nil {
+				_, err = r.readString()
+			}
+		case rdbOpFunction, rdbOpFunction2:
+			_, err = r.readString()
+		case rdbOpFreq:
+			_, err = r.readByte()
+		case rdbOpExpireTime:
+			var seconds uint32
+			if seconds, err = r.readUint32(); err == nil {
+				t := time.Unix(int64(seconds), 0)
+				expiresAt = &t
+			}
+		case rdbOpExpireTimeMs:
+			var ms uint64
+			if ms, err = r.readUint64(); err == nil {
+				t := time.UnixMilli(int64(ms))
+				expiresAt = &t
+			}
+		case rdbOpModuleAux:
+			return errors.New("failed to read redis dump: modules are not supported")
+		default:
+			var (
+				key   []byte
+				value []byte
+			)
+			if key, err = r.readString(); err != nil {
+				return err
+			}
+			if opcode != rdbTypeString {
+				if err = r.skipValue(opcode); err != nil {
+					return fmt.Errorf("failed to read redis key %q: %w", key, err)
+				}
+				skipped++
+				expiresAt = nil
+				continue
+			}
+			if value, err = r.readString(); err != nil {
+				return fmt.Errorf("failed to read redis key %q: %w", key, err)
+			}
+
+			if expiresAt != nil && expiresAt.Before(now) {
+				expiresAt = nil
+				continue
+			}
+			document := newDocument(string(key), info.ModTime(), database.BackupFile{
+				Name:      "untitled",
+				Content:   string(value),
+				ExpiresAt: expiresAt,
+			})
+			expiresAt = nil
+			if err = fn(document); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rdbReader decodes the redis rdb format, see https://rdb.fnordig.de/file_format.html
+type rdbReader struct {
+	r *bufio.Reader
+}
+
+func (r *rdbReader) readHeader() error {
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		return fmt.Errorf("failed to read redis dump header: %w", err)
+	}
+	if !bytes.HasPrefix(header, []byte("REDIS")) {
+		return errors.New("failed to read redis dump header: not a redis dump")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return fmt.Errorf("failed to read redis dump header: invalid version: %w", err)
+	}
+	if version > rdbMaxVersion {
+		return fmt.Errorf("failed to read redis dump header: unsupported version %d", version)
+	}
+	return nil
+}
+
+func (r *rdbReader) readByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read redis dump: %w", err)
+	}
+	return b, nil
+}
+
+func (r *rdbReader) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read redis dump: %w", err)
+	}
+	return buf, nil
+}
+
+func (r *rdbReader) readUint32() (uint32, error) {
+	buf, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+func (r *rdbReader) readUint64() (uint64, error) {
+	buf, err := r.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf), nil
+}
+
+// readEncodedLength reads a length, encoded is true if it is the type of a special string encoding instead.
+func (r *rdbReader) readEncodedLength() (uint64, bool, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3F), false, nil
+	case 1:
+		next, err := r.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3F)<<8 | uint64(next), false, nil
+	case 2:
+		switch b {
+		case 0x80:
+			buf, err := r.read(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(buf)), false, nil
+		case 0x81:
+			buf, err := r.read(8)
+			if err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(buf), false, nil
+		default:
+			return 0, false, fmt.Errorf("failed to read redis dump: invalid length encoding %#x", b)
+		}
+	default:
+		return uint64(b & 0x3F), true, nil
+	}
+}
+
+func (r *rdbReader) readLength() (uint64, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err != nil {
+		return 0, err
+	}
+	if encoded {
+		return 0, errors.New("failed to read redis dump: unexpected string encoding")
+	}
+	return length, nil
+}
+
+func (r *rdbReader) readString() ([]byte, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return r.read(int(length))
+	}
+
+	switch length {
+	case rdbEncodingInt8:
+		b, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int8(b)), 10), nil
+	case rdbEncodingInt16:
+		buf, err := r.read(2)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int16(binary.LittleEndian.Uint16(buf))), 10), nil
+	case rdbEncodingInt32:
+		buf, err := r.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, int64(int32(binary.LittleEndian.Uint32(buf))), 10), nil
+	case rdbEncodingLZF:
+		compressedLength, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		length, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := r.read(int(compressedLength))
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(compressed, int(length))
+	default:
+		return nil, fmt.Errorf("failed to read redis dump: unknown string encoding %d", length)
+	}
+}
+
+// skipValue reads a value of a type which is not imported.
+func (r *rdbReader) skipValue(valueType byte) error {
+	switch valueType {
+	case rdbTypeHashZipmap, rdbTypeListZiplist, rdbTypeSetIntset, rdbTypeZSetZiplist, rdbTypeHashZiplist, rdbTypeHashListpack, rdbTypeZSetListpack, rdbTypeSetListpack:
+		_, err := r.readString()
+		return err
+	case rdbTypeList, rdbTypeSet, rdbTypeListQuicklist:
+		return r.skipStrings(1, 0)
+	case rdbTypeHash:
+		return r.skipStrings(2, 0)
+	case rdbTypeZSet2:
+		return r.skipStrings(1, 8)
+	case rdbTypeListQuicklist2:
+		length, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for range length {
+			if _, err = r.readLength(); err != nil {
+				return err
+			}
+			if _, err = r.readString(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case rdbTypeZSet:
+		length, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for range length {
+			if _, err = r.readString(); err != nil {
+				return err
+			}
+			// scores are stored as strings with a one byte length, 253 to 255 are nan and infinity
+			scoreLength, err := r.readByte()
+			if err != nil {
+				return err
+			}
+			if scoreLength < 253 {
+				if _, err = r.read(int(scoreLength)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported value type %d", valueType)
+	}
+}
+
+// skipStrings reads a length followed by that many entries of stringsPerEntry strings and extra bytes.
+func (r *rdbReader) skipStrings(stringsPerEntry int, extra int) error {
+	length, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for range length {
+		for range stringsPerEntry {
+			if _, err = r.readString(); err != nil {
+				return err
+			}
+		}
+		if extra > 0 {
+			if _, err = r.read(extra); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lzfDecompress decompresses data compressed with liblzf, which redis uses for long strings.
+func lzfDecompress(in []byte, length int) ([]byte, error) {
+	out := make([]byte, 0, length)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+
+		if ctrl < 32 {
+			// literal run of ctrl + 1 bytes
+			end := i + ctrl + 1
+			if end > len(in) {
+				return nil, errors.New("failed to decompress redis string: literal out of bounds")
+			}
+			out = append(out, in[i:end]...)
+			i = end
+			continue
+		}
+
+		// back reference of (ctrl >> 5) + 2 bytes
+		refLength := ctrl >> 5
+		if refLength == 7 {
+			if i >= len(in) {
+				return nil, errors.New("failed to decompress redis string: unexpected end")
+			}
+			refLength += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, errors.New("failed to decompress redis string: unexpected end")
+		}
+		ref := len(out) - (ctrl&0x1F)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, errors.New("failed to decompress redis string: reference out of bounds")
+		}
+		for j := range refLength + 2 {
+			out = append(out, out[ref+j])
+		}
+	}
+	if len(out) != length {
+		return nil, fmt.Errorf("failed to decompress redis string: expected %d bytes, got %d", length, len(out))
+	}
+	return out, nil
+}
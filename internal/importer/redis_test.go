@@ -0,0 +1,227 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// rdb builds a redis dump of version 9 with the given entries followed by the end of file opcode and an empty checksum.
+func rdb(entries ...[]byte) []byte {
+	dump := []byte("REDIS0009")
+	for _, entry := range entries {
+		dump = append(dump, entry...)
+	}
+	dump = append(dump, rdbOpEOF)
+	return append(dump, make([]byte, 8)...)
+}
+
+// rdbString encodes s as a length prefixed string, s has to be shorter than 64 bytes.
+func rdbString(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// rdbEntry encodes a key with the value type and the already encoded value.
+func rdbEntry(valueType byte, key string, value ...[]byte) []byte {
+	entry := append([]byte{valueType}, rdbString(key)...)
+	for _, v := range value {
+		entry = append(entry, v...)
+	}
+	return entry
+}
+
+func readRedisDump(t *testing.T, dump []byte) ([]database.BackupDocument, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dump.rdb")
+	if err := os.WriteFile(path, dump, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var documents []database.BackupDocument
+	err := NewRedisDumpSource(path).Read(context.Background(), func(document database.BackupDocument) error {
+		documents = append(documents, document)
+		return nil
+	})
+	return documents, err
+}
+
+func TestRedisDumpSource(t *testing.T) {
+	future := time.UnixMilli(4102444800000)
+
+	type document struct {
+		key       string
+		content   string
+		expiresAt *time.Time
+	}
+	tests := []struct {
+		name string
+		dump []byte
+		want []document
+	}{
+		{
+			name: "empty",
+			dump: rdb(),
+			want: nil,
+		},
+		{
+			name: "strings",
+			dump: rdb(
+				rdbEntry(rdbTypeString, "abc", rdbString("hello")),
+				rdbEntry(rdbTypeString, "def", rdbString("")),
+			),
+			want: []document{{key: "abc", content: "hello"}, {key: "def", content: ""}},
+		},
+		{
+			name: "long length",
+			dump: rdb(
+				rdbEntry(rdbTypeString, "abc", []byte{0x40, 0x41}, bytes.Repeat([]byte("a"), 65)),
+				rdbEntry(rdbTypeString, "def", []byte{0x80, 0, 0, 0, 2}, []byte("hi")),
+			),
+			want: []document{{key: "abc", content: string(bytes.Repeat([]byte("a"), 65))}, {key: "def", content: "hi"}},
+		},
+		{
+			name: "int encoded strings",
+			dump: rdb(
+				rdbEntry(rdbTypeString, "int8", []byte{0xC0, 0x85}),
+				rdbEntry(rdbTypeString, "int16", []byte{0xC1, 0x39, 0x30}),
+				rdbEntry(rdbTypeString, "int32", []byte{0xC2, 0xFF, 0xFF, 0xFF, 0xFF}),
+			),
+			want: []document{{key: "int8", content: "-123"}, {key: "int16", content: "12345"}, {key: "int32", content: "-1"}},
+		},
+		{
+			name: "lzf compressed string",
+			dump: rdb(
+				// compressed length 7, length 12: literal "abc" followed by a back reference of 9 bytes to it
+				rdbEntry(rdbTypeString, "abc", []byte{0xC3, 7, 12, 0x02, 'a', 'b', 'c', 0xE0, 0x00, 0x02}),
+			),
+			want: []document{{key: "abc", content: "abcabcabcabc"}},
+		},
+		{
+			name: "metadata",
+			dump: rdb(
+				[]byte{rdbOpAux}, rdbString("redis-ver"), rdbString("7.2.4"),
+				[]byte{rdbOpAux}, rdbString("ctime"), []byte{0xC2, 0x00, 0x00, 0x00, 0x60},
+				[]byte{rdbOpSelectDB, 0},
+				[]byte{rdbOpResizeDB, 1, 0},
+				rdbEntry(rdbTypeString, "abc", rdbString("hello")),
+			),
+			want: []document{{key: "abc", content: "hello"}},
+		},
+		{
+			name: "skipped types",
+			dump: rdb(
+				rdbEntry(rdbTypeList, "list", []byte{2}, rdbString("a"), rdbString("b")),
+				rdbEntry(rdbTypeSet, "set", []byte{1}, rdbString("a")),
+				rdbEntry(rdbTypeHash, "hash", []byte{1}, rdbString("field"), rdbString("value")),
+				rdbEntry(rdbTypeZSet, "zset", []byte{2}, rdbString("a"), rdbString("1.5"), rdbString("b"), []byte{254}),
+				rdbEntry(rdbTypeZSet2, "zset2", []byte{1}, rdbString("a"), make([]byte, 8)),
+				rdbEntry(rdbTypeHashListpack, "listpack", rdbString("\x00\x01\x02")),
+				rdbEntry(rdbTypeListQuicklist2, "quicklist", []byte{1, 2}, rdbString("\x00\x01")),
+				rdbEntry(rdbTypeString, "abc", rdbString("hello")),
+			),
+			want: []document{{key: "abc", content: "hello"}},
+		},
+		{
+			name: "expiry",
+			dump: rdb(
+				[]byte{rdbOpExpireTimeMs, 0x00, 0xD8, 0xC3, 0x2C, 0xBB, 0x03, 0x00, 0x00},
+				rdbEntry(rdbTypeString, "future", rdbString("a")),
+				rdbEntry(rdbTypeString, "none", rdbString("b")),
+				[]byte{rdbOpExpireTime, 0x00, 0xCA, 0x9A, 0x3B},
+				rdbEntry(rdbTypeString, "expired", rdbString("c")),
+				[]byte{rdbOpExpireTimeMs, 0x00, 0xD8, 0xC3, 0x2C, 0xBB, 0x03, 0x00, 0x00},
+				rdbEntry(rdbTypeList, "list", []byte{1}, rdbString("a")),
+				rdbEntry(rdbTypeString, "after-skipped", rdbString("d")),
+			),
+			want: []document{{key: "future", content: "a", expiresAt: &future}, {key: "none", content: "b"}, {key: "after-skipped", content: "d"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := readRedisDump(t, tt.dump)
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			var got []document
+			for _, d := range documents {
+				if len(d.Versions) != 1 || len(d.Versions[0].Files) != 1 {
+					t.Fatalf("Read() document %s has %d versions, want one version with one file", d.ID, len(d.Versions))
+				}
+				file := d.Versions[0].Files[0]
+				got = append(got, document{key: d.ID, content: file.Content, expiresAt: file.ExpiresAt})
+			}
+			if !slices.EqualFunc(got, tt.want, func(a document, b document) bool {
+				return a.key == b.key && a.content == b.content && (a.expiresAt == nil) == (b.expiresAt == nil) && (a.expiresAt == nil || a.expiresAt.Equal(*b.expiresAt))
+			}) {
+				t.Errorf("Read() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisDumpSourceInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		dump []byte
+	}{
+		{name: "not a redis dump", dump: []byte("NOTREDIS9")},
+		{name: "unsupported version", dump: []byte("REDIS0099\xff")},
+		{name: "truncated header", dump: []byte("REDIS")},
+		{name: "missing end of file", dump: append([]byte("REDIS0009"), rdbEntry(rdbTypeString, "abc", rdbString("hello"))...)},
+		{name: "truncated value", dump: append([]byte("REDIS0009"), rdbEntry(rdbTypeString, "abc", []byte{5, 'h'})...)},
+		{name: "unknown string encoding", dump: rdb(rdbEntry(rdbTypeString, "abc", []byte{0xC4}))},
+		{name: "unsupported value type", dump: rdb(rdbEntry(7, "abc", rdbString("hello")))},
+		{name: "modules", dump: rdb([]byte{rdbOpModuleAux})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readRedisDump(t, tt.dump); err == nil {
+				t.Error("Read() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestLZFDecompress(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      []byte
+		length  int
+		want    string
+		wantErr bool
+	}{
+		{name: "literal", in: []byte{0x02, 'a', 'b', 'c'}, length: 3, want: "abc"},
+		{name: "short back reference", in: []byte{0x02, 'a', 'b', 'c', 0x20, 0x02}, length: 6, want: "abcabc"},
+		{name: "long back reference", in: []byte{0x00, 'a', 0xE0, 0x01, 0x00}, length: 11, want: "aaaaaaaaaaa"},
+		{name: "back reference with offset", in: []byte{0x03, 'a', 'b', 'c', 'd', 0x20, 0x03, 0x00, 'e'}, length: 8, want: "abcdabce"},
+		{name: "literal out of bounds", in: []byte{0x05, 'a'}, length: 6, wantErr: true},
+		{name: "back reference out of bounds", in: []byte{0x00, 'a', 0x20, 0x05}, length: 4, wantErr: true},
+		{name: "missing back reference offset", in: []byte{0x00, 'a', 0x20}, length: 4, wantErr: true},
+		{name: "missing long back reference length", in: []byte{0x00, 'a', 0xE0}, length: 10, wantErr: true},
+		{name: "wrong length", in: []byte{0x02, 'a', 'b', 'c'}, length: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lzfDecompress(tt.in, tt.length)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("lzfDecompress() = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lzfDecompress() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("lzfDecompress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
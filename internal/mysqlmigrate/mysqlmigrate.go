@@ -0,0 +1,59 @@
+// Package mysqlmigrate provides a gomigrate driver for MySQL and MariaDB.
+package mysqlmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/topi314/gomigrate"
+)
+
+// Name is the name of the MySQL driver.
+const Name = "mysql"
+
+// New returns a new MySQL driver.
+// The connection has to allow multiple statements per query, as migrations are executed as a whole.
+func New(db gomigrate.Queryer, tableName string) gomigrate.Driver {
+	return &driver{
+		db:        db,
+		tableName: tableName,
+	}
+}
+
+type driver struct {
+	db        gomigrate.Queryer
+	tableName string
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) CreateVersionTable(ctx context.Context) error {
+	_, err := d.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version INT PRIMARY KEY, date TIMESTAMP DEFAULT CURRENT_TIMESTAMP)", d.tableName))
+	return err
+}
+
+func (d *driver) GetVersion(ctx context.Context) (int, error) {
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s ORDER BY version DESC LIMIT 1", d.tableName))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+
+	var v int
+	if err = rows.Scan(&v); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+func (d *driver) AddVersion(ctx context.Context, tx *sql.Tx, version int) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version) VALUES (?)", d.tableName), version)
+	return err
+}
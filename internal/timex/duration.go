@@ -14,3 +14,7 @@ func (d *Duration) UnmarshalText(text []byte) error {
 	*d = Duration(duration)
 	return nil
 }
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"database/sql"
 	"embed"
 	"flag"
+	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,6 +31,8 @@ import (
 	meternoop "go.opentelemetry.io/otel/metric/noop"
 	tracenoop "go.opentelemetry.io/otel/trace/noop"
 
+	"github.com/topi314/gobin/v2/internal/importer"
+	"github.com/topi314/gobin/v2/internal/mysqlmigrate"
 	"github.com/topi314/gobin/v2/internal/ver"
 	"github.com/topi314/gobin/v2/server"
 	"github.com/topi314/gobin/v2/server/database"
@@ -63,6 +71,8 @@ func main() {
 		return
 	}
 
+	cfg.Database.IDs.Reserved = cfg.Slugs.ReservedSlugs()
+
 	setupLogger(cfg.Log)
 	buildTime, _ := time.Parse(time.RFC3339, BuildTime)
 	slog.Info("Starting Gobin...", slog.String("version", Version), slog.String("commit", Commit), slog.Time("build-time", buildTime))
@@ -87,9 +97,15 @@ func main() {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	db, err := database.New(ctx, cfg.Database)
+
+	var db database.Store
+	if cfg.Database.Type == database.TypeMemory {
+		db, err = database.NewMemoryStore(cfg.Database)
+	} else {
+		db, err = openDatabase(ctx, cfg.Database)
+	}
 	if err != nil {
-		slog.Error("Error while connecting to database", tint.Err(err))
+		slog.Error("Error while opening database", tint.Err(err))
 		return
 	}
 	defer func() {
@@ -98,16 +114,27 @@ func main() {
 		}
 	}()
 
-	var driver gomigrate.NewDriver
-	switch cfg.Database.Type {
-	case database.TypePostgres:
-		driver = postgres.New
-	case database.TypeSQLite:
-		driver = sqlite.New
-	}
-
-	if err = gomigrate.Migrate(ctx, db, driver, Migrations, gomigrate.WithDirectory("server/migrations")); err != nil {
-		slog.Error("Error while migrating database", tint.Err(err))
+	switch command := flag.Arg(0); command {
+	case "":
+	case "rotate-keys":
+		sqlDB, ok := db.(*database.DB)
+		if !ok {
+			slog.Error("Command is not supported by the memory database", slog.String("command", command))
+			return
+		}
+		rotateKeys(sqlDB)
+		return
+	case "backup":
+		backup(db, flag.Arg(1))
+		return
+	case "restore":
+		restore(db, flag.Arg(1))
+		return
+	case "import":
+		importDocuments(db, cfg.Slugs.ReservedSlugs(), flag.Args()[1:])
+		return
+	default:
+		slog.Error("Unknown command", slog.String("command", command))
 		return
 	}
 
@@ -167,6 +194,217 @@ func main() {
 	<-si
 }
 
+// openDatabase connects to the configured database and migrates it to the latest schema version.
+func openDatabase(ctx context.Context, cfg database.Config) (*database.DB, error) {
+	db, err := database.New(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	var (
+		migrationDB   gomigrate.Queryer = db
+		driver        gomigrate.NewDriver
+		migrationsDir = "server/migrations"
+	)
+	switch cfg.Type {
+	case database.TypePostgres:
+		driver = postgres.New
+	case database.TypeSQLite:
+		driver = sqlite.New
+	case database.TypeMySQL:
+		// only the migrations are allowed to send multiple statements per query
+		mysqlDB, err := openMySQLMigrationDB(cfg)
+		if err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		defer mysqlDB.Close()
+		migrationDB = mysqlDB
+		driver = mysqlmigrate.New
+		migrationsDir = "server/migrations/mysql"
+	}
+
+	if err = gomigrate.Migrate(ctx, migrationDB, driver, Migrations, gomigrate.WithDirectory(migrationsDir)); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// openMySQLMigrationDB opens a connection to the mysql database which can execute a migration as a whole.
+func openMySQLMigrationDB(cfg database.Config) (*sql.DB, error) {
+	dsn, err := cfg.MySQLMigrationDataSourceName()
+	if err != nil {
+		return nil, err
+	}
+	mysqlDB, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database for migrations: %w", err)
+	}
+	return mysqlDB, nil
+}
+
+// rotateKeys re-encrypts all contents with the current encryption key until it is done or interrupted.
+func rotateKeys(db *database.DB) {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	slog.Info("Rotating encryption keys...")
+	count, err := db.RotateKeys(ctx)
+	if err != nil {
+		slog.Error("Error while rotating encryption keys", slog.Int("count", count), tint.Err(err))
+		return
+	}
+	slog.Info("Rotated encryption keys", slog.Int("count", count))
+}
+
+// backup writes all documents to the file, which is gzip compressed if its name ends with ".gz".
+func backup(db database.Store, path string) {
+	if path == "" {
+		slog.Error("Missing backup file, usage: gobin backup <file>")
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// write to a temporary file first, so an interrupted backup doesn't replace an older one
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		slog.Error("Error while creating backup file", tint.Err(err))
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	var (
+		w  io.Writer = file
+		gw *gzip.Writer
+	)
+	if strings.HasSuffix(path, ".gz") {
+		gw = gzip.NewWriter(file)
+		w = gw
+	}
+
+	slog.Info("Writing backup...", slog.String("file", path))
+	stats, err := database.WriteBackup(ctx, db, w, Version)
+	if err != nil {
+		slog.Error("Error while writing backup", slog.Int("documents", stats.Documents), tint.Err(err))
+		return
+	}
+	if gw != nil {
+		if err = gw.Close(); err != nil {
+			slog.Error("Error while writing backup", tint.Err(err))
+			return
+		}
+	}
+	if err = file.Close(); err != nil {
+		slog.Error("Error while writing backup", tint.Err(err))
+		return
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		slog.Error("Error while writing backup", tint.Err(err))
+		return
+	}
+	slog.Info("Wrote backup", slog.Int("documents", stats.Documents), slog.Int("versions", stats.Versions))
+}
+
+// restore imports all documents from the backup file, which is gzip compressed if its name ends with ".gz".
+func restore(db database.Store, path string) {
+	if path == "" {
+		slog.Error("Missing backup file, usage: gobin restore <file>")
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	file, err := os.Open(path)
+	if err != nil {
+		slog.Error("Error while opening backup file", tint.Err(err))
+		return
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			slog.Error("Error while reading backup", tint.Err(err))
+			return
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	slog.Info("Restoring backup...", slog.String("file", path))
+	stats, err := database.RestoreBackup(ctx, db, r)
+	if err != nil {
+		slog.Error("Error while restoring backup", slog.Int("documents", stats.Documents), slog.Int("skipped", stats.Skipped), tint.Err(err))
+		return
+	}
+	slog.Info("Restored backup", slog.Int("documents", stats.Documents), slog.Int("versions", stats.Versions), slog.Int("skipped", stats.Skipped))
+}
+
+// importDocuments imports the documents of another paste service with their original keys.
+func importDocuments(db database.Store, reservedSlugs []string, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: gobin import [flags] <haste-file|haste-redis|directory> <path>")
+		flags.PrintDefaults()
+	}
+	dryRun := flags.Bool("dry-run", false, "only report what would be imported")
+	resumeFile := flags.String("resume", "", "file to record imported keys in, keys it already contains are skipped")
+	keysFile := flags.String("keys", "", "file with one haste-server key per line, required to keep the keys of the haste-server file store")
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return
+	}
+
+	var source importer.Source
+	switch sourceType, path := flags.Arg(0), flags.Arg(1); sourceType {
+	case "haste-file":
+		var keys []string
+		if *keysFile != "" {
+			data, err := os.ReadFile(*keysFile)
+			if err != nil {
+				slog.Error("Error while reading keys file", tint.Err(err))
+				return
+			}
+			keys = strings.Fields(string(data))
+		}
+		source = importer.NewHasteFileSource(path, keys)
+	case "haste-redis":
+		source = importer.NewRedisDumpSource(path)
+	case "directory":
+		source = importer.NewDirectorySource(path)
+	default:
+		slog.Error("Unknown import source", slog.String("source", sourceType))
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	slog.Info("Importing documents...", slog.Bool("dry-run", *dryRun))
+	stats, err := importer.Import(ctx, db, source, importer.Config{
+		DryRun:        *dryRun,
+		ResumeFile:    *resumeFile,
+		ReservedSlugs: reservedSlugs,
+	})
+	attrs := []any{slog.Int("imported", stats.Imported), slog.Int("existing", stats.Existing), slog.Int("resumed", stats.Resumed), slog.Int("reserved", stats.Reserved)}
+	if err != nil {
+		slog.Error("Error while importing documents", append(attrs, tint.Err(err))...)
+		return
+	}
+	if *dryRun {
+		slog.Info("Would import documents", attrs...)
+		return
+	}
+	slog.Info("Imported documents", attrs...)
+}
+
 const (
 	ansiFaint         = "\033[2m"
 	ansiWhiteBold     = "\033[37;1m"
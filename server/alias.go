@@ -0,0 +1,155 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/topi314/gobin/v2/internal/flags"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// reservedSlugs are always reserved because they are used as route prefixes.
+var reservedSlugs = []string{"raw", "documents", "assets", "version", "ping", "debug", "admin"}
+
+var (
+	ErrAliasNotFound = errors.New("alias not found")
+	ErrInvalidSlug   = func(pattern string) error {
+		return fmt.Errorf("invalid slug, must match %s", pattern)
+	}
+	ErrReservedSlug = errors.New("slug is reserved")
+)
+
+type (
+	AliasRequest struct {
+		Alias string `json:"alias"`
+	}
+
+	AliasesResponse struct {
+		Key     string   `json:"key"`
+		Aliases []string `json:"aliases"`
+	}
+)
+
+func (s *Server) validateSlug(slug string) error {
+	if !s.slugPattern.MatchString(slug) {
+		return httperr.BadRequest(ErrInvalidSlug(s.cfg.Slugs.Pattern))
+	}
+	if slices.ContainsFunc(s.cfg.Slugs.ReservedSlugs(), func(reserved string) bool {
+		return strings.EqualFold(reserved, slug)
+	}) {
+		return httperr.BadRequest(ErrReservedSlug)
+	}
+	return nil
+}
+
+func (s *Server) GetDocumentAliases(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	aliases, err := s.db.GetDocumentAliases(r.Context(), documentID)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	s.ok(w, r, AliasesResponse{
+		Key:     documentID,
+		Aliases: aliases,
+	})
+}
+
+func (s *Server) PostDocumentAlias(w http.ResponseWriter, r *http.Request) {
+	var aliasRequest AliasRequest
+	if err := json.NewDecoder(r.Body).Decode(&aliasRequest); err != nil {
+		s.error(w, r, httperr.BadRequest(err))
+		return
+	}
+
+	if err := s.validateSlug(aliasRequest.Alias); err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionWrite) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("write")))
+		return
+	}
+
+	if err = s.db.CreateDocumentAlias(r.Context(), documentID, aliasRequest.Alias); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		if errors.Is(err, database.ErrSlugTaken) {
+			s.error(w, r, httperr.Conflict(database.ErrSlugTaken))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	aliases, err := s.db.GetDocumentAliases(r.Context(), documentID)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	s.json(w, r, AliasesResponse{
+		Key:     documentID,
+		Aliases: aliases,
+	}, http.StatusCreated)
+}
+
+func (s *Server) DeleteDocumentAlias(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	alias := chi.URLParam(r, "alias")
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionWrite) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("write")))
+		return
+	}
+
+	if err = s.db.DeleteDocumentAlias(r.Context(), documentID, alias); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrAliasNotFound))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	s.ok(w, r, nil)
+}
+
+// newSlugPattern compiles the configured slug pattern.
+func newSlugPattern(cfg SlugConfig) (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slug pattern: %w", err)
+	}
+	return pattern, nil
+}
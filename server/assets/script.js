@@ -378,6 +378,26 @@ document.getElementById("raw").addEventListener("click", () => {
     window.open(`/raw/${key}${version !== 0 ? `/versions/${version}` : ""}`, "_blank").focus();
 })
 
+document.getElementById("compare").addEventListener("click", () => {
+    if (document.getElementById("compare").disabled) {
+        return;
+    }
+
+    const {key, version} = getState();
+    if (!key) return;
+    window.open(`/${key}/compare${version !== 0 ? `/...${version}` : ""}`, "_blank").focus();
+})
+
+document.getElementById("blame").addEventListener("click", () => {
+    if (document.getElementById("blame").disabled) {
+        return;
+    }
+
+    const {key, files, current_file} = getState();
+    if (!key) return;
+    window.open(`/${key}/blame/${encodeURIComponent(files[current_file].name)}`, "_blank").focus();
+})
+
 document.getElementById("share").addEventListener("click", async () => {
     if (document.getElementById("share").disabled) return;
 
@@ -700,6 +720,8 @@ function updateButtons(state) {
     const deleteButton = document.getElementById("delete");
     const copyButton = document.getElementById("copy");
     const rawButton = document.getElementById("raw");
+    const compareButton = document.getElementById("compare");
+    const blameButton = document.getElementById("blame");
     const shareButton = document.getElementById("share");
     const expireLabel = document.querySelector(`label[for="expire"]`);
     const versionSelect = document.getElementById("version");
@@ -711,6 +733,8 @@ function updateButtons(state) {
         deleteButton.disabled = !hasPermission(token, PermissionDelete);
         copyButton.disabled = false;
         rawButton.disabled = false;
+        compareButton.disabled = false;
+        blameButton.disabled = false;
         shareButton.disabled = false;
         expireLabel.style.display = "none";
         return;
@@ -722,6 +746,8 @@ function updateButtons(state) {
     deleteButton.disabled = true;
     copyButton.disabled = true;
     rawButton.disabled = true;
+    compareButton.disabled = true;
+    blameButton.disabled = true;
     shareButton.disabled = true;
     expireLabel.style.display = "block";
 }
@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/topi314/tint"
+
+	"github.com/topi314/gobin/v2/internal/diff"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
+	"github.com/topi314/gobin/v2/server/templates"
+)
+
+type (
+	BlameResponse struct {
+		Key      string `json:"key"`
+		Version  int64  `json:"version"`
+		Name     string `json:"name"`
+		Language string `json:"language"`
+		// Truncated is set if not all versions were searched, lines attributed to the oldest searched version may be older.
+		Truncated bool        `json:"truncated,omitempty"`
+		Lines     []BlameLine `json:"lines"`
+	}
+
+	BlameLine struct {
+		Number         int    `json:"number"`
+		Content        string `json:"content"`
+		Version        int64  `json:"version"`
+		VersionLabel   string `json:"version_label"`
+		VersionTime    string `json:"version_time"`
+		VersionMessage string `json:"version_message,omitempty"`
+		VersionAuthor  string `json:"version_author,omitempty"`
+	}
+)
+
+// maxBlameVersions is how many versions are searched at most to find the version which introduced a line.
+const maxBlameVersions = 100
+
+// blameLine is a line of a file with the version which introduced it.
+type blameLine struct {
+	version int64
+	text    string
+}
+
+// fileBlame is the blame of a file in the latest version of a document.
+type fileBlame struct {
+	documentID string
+	file       *database.File
+	lines      []blameLine
+	truncated  bool
+	versions   map[int64]templates.DocumentVersion
+}
+
+// GetDocumentFileBlame attributes every line of a file in the latest document version to the version which introduced it.
+func (s *Server) GetDocumentFileBlame(w http.ResponseWriter, r *http.Request) {
+	blame, err := s.blameDocumentFile(r.Context(), chi.URLParam(r, "documentID"), chi.URLParam(r, "fileName"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	response := BlameResponse{
+		Key:       blame.documentID,
+		Version:   blame.file.DocumentVersion,
+		Name:      blame.file.Name,
+		Language:  blame.file.Language,
+		Truncated: blame.truncated,
+		Lines:     make([]BlameLine, len(blame.lines)),
+	}
+	for i, line := range blame.lines {
+		version := blame.versions[line.version]
+		response.Lines[i] = BlameLine{
+			Number:         i + 1,
+			Content:        strings.TrimSuffix(line.text, "\n"),
+			Version:        line.version,
+			VersionLabel:   version.Label,
+			VersionTime:    version.Time,
+			VersionMessage: version.Message,
+			VersionAuthor:  version.Author,
+		}
+	}
+	s.ok(w, r, response)
+}
+
+// GetPrettyBlame renders the blame of a file with syntax highlighting, every version links to its changes.
+func (s *Server) GetPrettyBlame(w http.ResponseWriter, r *http.Request) {
+	blame, err := s.blameDocumentFile(r.Context(), chi.URLParam(r, "documentID"), chi.URLParam(r, "fileName"))
+	if err != nil {
+		s.prettyError(w, r, err)
+		return
+	}
+
+	highlighted := s.highlightLines(*blame.file)
+	lines := make([]templates.BlameLine, len(blame.lines))
+	for i, line := range blame.lines {
+		lines[i] = templates.BlameLine{
+			Number:  i + 1,
+			Version: blame.versions[line.version],
+			First:   i == 0 || blame.lines[i-1].version != line.version,
+			HTML:    highlightLine(lineTokens(highlighted, i+1, strings.TrimSuffix(line.text, "\n")), nil),
+		}
+	}
+
+	style := getStyle(r)
+	if err = templates.Blame(templates.BlameVars{
+		ID:        blame.documentID,
+		FileName:  blame.file.Name,
+		Lines:     lines,
+		Truncated: blame.truncated,
+
+		Style: style.Name,
+		Theme: style.Theme,
+	}).Render(r.Context(), w); err != nil {
+		slog.ErrorContext(r.Context(), "failed to execute template", tint.Err(err))
+	}
+}
+
+func (s *Server) blameDocumentFile(ctx context.Context, documentID string, fileName string) (*fileBlame, error) {
+	documentID, err := s.resolveDocumentID(ctx, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.db.GetDocumentVersions(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+	if len(versions) == 0 {
+		return nil, httperr.NotFound(ErrDocumentNotFound)
+	}
+	s.touchDocument(ctx, documentID)
+
+	file, lines, truncated, err := blameFile(versions, maxBlameVersions, func(version int64) (*database.File, error) {
+		file, err := s.db.GetDocumentFileVersion(ctx, documentID, version, fileName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return file, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame document file: %w", err)
+	}
+	if file == nil {
+		return nil, httperr.NotFound(ErrDocumentFileNotFound)
+	}
+
+	documentVersions, err := s.db.GetDocumentVersionsWithInfo(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+	templateVersions := make(map[int64]templates.DocumentVersion, len(documentVersions))
+	for _, version := range newTemplateVersions(documentVersions) {
+		templateVersions[version.Version] = version
+	}
+
+	return &fileBlame{
+		documentID: documentID,
+		file:       file,
+		lines:      lines,
+		truncated:  truncated,
+		versions:   templateVersions,
+	}, nil
+}
+
+// blameFile walks the versions from newest to oldest and diffs every change of the file, lines found unchanged in an older version are attributed to it.
+// The walk stops once every line has its version, at a version without the file or after maxVersions versions, in which case truncated is set.
+// So a file which was removed and added again is attributed to the version which added it again.
+// versions have to be sorted from newest to oldest and getFile returns nil if the file does not exist in a version.
+// It returns nil if the file does not exist in the latest version.
+func blameFile(versions []int64, maxVersions int, getFile func(version int64) (*database.File, error)) (*database.File, []blameLine, bool, error) {
+	if len(versions) == 0 {
+		return nil, nil, false, nil
+	}
+	file, err := getFile(versions[0])
+	if err != nil || file == nil {
+		return nil, nil, false, err
+	}
+
+	texts := diff.SplitLines(file.Content)
+	lines := make([]blameLine, len(texts))
+	// origins maps the lines of the current version to the lines of the latest version, -1 for lines which already have their version
+	origins := make([]int, len(texts))
+	for i, text := range texts {
+		lines[i] = blameLine{version: versions[0], text: text}
+		origins[i] = i
+	}
+
+	current := file
+	remaining := len(lines)
+	for i, version := range versions[1:] {
+		if remaining == 0 {
+			break
+		}
+		if i+1 >= maxVersions {
+			return file, lines, true, nil
+		}
+
+		older, err := getFile(version)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if older == nil {
+			break
+		}
+
+		olderTexts := diff.SplitLines(older.Content)
+		olderOrigins := make([]int, len(olderTexts))
+		for j := range olderOrigins {
+			olderOrigins[j] = -1
+		}
+		if older.ContentHash == current.ContentHash {
+			copy(olderOrigins, origins)
+		} else {
+			for _, edit := range diff.Diff(olderTexts, texts) {
+				if edit.Op != diff.OpEqual {
+					continue
+				}
+				copy(olderOrigins[edit.AStart:edit.AEnd], origins[edit.BStart:edit.BEnd])
+			}
+		}
+
+		remaining = 0
+		for _, origin := range olderOrigins {
+			if origin >= 0 {
+				lines[origin].version = version
+				remaining++
+			}
+		}
+		current, texts, origins = older, olderTexts, olderOrigins
+	}
+	return file, lines, false, nil
+}
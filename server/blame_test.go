@@ -0,0 +1,115 @@
+package server
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+func TestBlameFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    []string
+		maxVersions int
+		want        []int64
+		truncated   bool
+	}{
+		{name: "single version", contents: []string{"a\nb\n"}, want: []int64{1, 1}},
+		{name: "appended line", contents: []string{"a\n", "a\nb\n"}, want: []int64{1, 2}},
+		{name: "changed line", contents: []string{"a\nb\nc\n", "a\nx\nc\n"}, want: []int64{1, 2, 1}},
+		{name: "unchanged versions keep the oldest version", contents: []string{"a\n", "a\n", "a\nb\n", "a\nb\n"}, want: []int64{1, 3}},
+		{name: "line changed back", contents: []string{"a\n", "b\n", "a\n"}, want: []int64{3}},
+		{name: "lines from different versions", contents: []string{"a\n", "a\nb\n", "a\nb\nc\n", "x\nb\nc\n"}, want: []int64{4, 2, 3}},
+		{name: "missing trailing newline", contents: []string{"a\nb", "a\nb\n"}, want: []int64{1, 2}},
+		{name: "file removed and added again", contents: []string{"a\n", "", "a\nb\n"}, want: []int64{3, 3}},
+		{name: "removed file", contents: []string{"a\n", ""}, want: nil},
+		{name: "truncated", contents: []string{"a\n", "a\nb\n", "a\nb\nc\n"}, maxVersions: 2, want: []int64{2, 2, 3}, truncated: true},
+		{name: "not truncated when all lines have their version", contents: []string{"a\n", "a\n", "x\n"}, maxVersions: 2, want: []int64{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// version i+1 has the content contents[i], an empty content means the file does not exist in that version
+			versions := make([]int64, len(tt.contents))
+			for i := range tt.contents {
+				versions[len(versions)-1-i] = int64(i + 1)
+			}
+			maxVersions := tt.maxVersions
+			if maxVersions == 0 {
+				maxVersions = maxBlameVersions
+			}
+
+			file, lines, truncated, err := blameFile(versions, maxVersions, func(version int64) (*database.File, error) {
+				content := tt.contents[version-1]
+				if content == "" {
+					return nil, nil
+				}
+				return &database.File{
+					Name:            "main.go",
+					Content:         content,
+					ContentHash:     content,
+					DocumentVersion: version,
+				}, nil
+			})
+			if err != nil {
+				t.Fatalf("blameFile() error = %v", err)
+			}
+			if tt.want == nil {
+				if file != nil {
+					t.Fatalf("blameFile() file = %v, want nil", file)
+				}
+				return
+			}
+			if file == nil {
+				t.Fatal("blameFile() file = nil")
+			}
+
+			var got []int64
+			var text string
+			for _, line := range lines {
+				got = append(got, line.version)
+				text += line.text
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("blameFile() versions = %v, want %v", got, tt.want)
+			}
+			if text != file.Content {
+				t.Errorf("blameFile() lines = %q, want %q", text, file.Content)
+			}
+			if truncated != tt.truncated {
+				t.Errorf("blameFile() truncated = %t, want %t", truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestBlameFileLoadsOnlyNeededVersions(t *testing.T) {
+	var loaded []int64
+	_, _, _, err := blameFile([]int64{4, 3, 2, 1}, maxBlameVersions, func(version int64) (*database.File, error) {
+		loaded = append(loaded, version)
+		content := "a\n"
+		if version < 3 {
+			content = "b\n"
+		}
+		return &database.File{Name: "main.go", Content: content, ContentHash: content}, nil
+	})
+	if err != nil {
+		t.Fatalf("blameFile() error = %v", err)
+	}
+	if want := []int64{4, 3, 2}; !slices.Equal(loaded, want) {
+		t.Errorf("blameFile() loaded versions %v, want %v", loaded, want)
+	}
+}
+
+func TestBlameFileError(t *testing.T) {
+	wantErr := errors.New("failed to load file")
+	if _, _, _, err := blameFile([]int64{2, 1}, maxBlameVersions, func(version int64) (*database.File, error) {
+		if version == 1 {
+			return nil, wantErr
+		}
+		return &database.File{Name: "main.go", Content: "a\nb\n", ContentHash: "2"}, nil
+	}); !errors.Is(err, wantErr) {
+		t.Errorf("blameFile() error = %v, want %v", err, wantErr)
+	}
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"html"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/topi314/chroma/v2"
+	"github.com/topi314/tint"
+
+	"github.com/topi314/gobin/v2/internal/diff"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
+	"github.com/topi314/gobin/v2/server/templates"
+)
+
+// compareLine is a changed line waiting to be paired with a line of the other side.
+type compareLine struct {
+	number int
+	text   string
+}
+
+// GetPrettyCompare renders two versions of a document side by side.
+// The versions are taken from the path like /{documentID}/compare/{from}...{to} or from the from and to query parameters
+// which are sent by the version form, both are handled like in GetDocumentDiff.
+func (s *Server) GetPrettyCompare(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.prettyError(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	fromStr, toStr := query.Get("from"), query.Get("to")
+	if versions := chi.URLParam(r, "versions"); versions != "" {
+		var ok bool
+		if fromStr, toStr, ok = strings.Cut(versions, "..."); !ok {
+			s.prettyError(w, r, httperr.BadRequest(ErrInvalidDocumentVersion))
+			return
+		}
+	}
+
+	versionDiff, err := s.diffVersions(r.Context(), documentID, fromStr, toStr)
+	if err != nil {
+		s.prettyError(w, r, err)
+		return
+	}
+	s.touchDocument(r.Context(), documentID)
+
+	files := make([]templates.CompareFile, len(versionDiff.diffs))
+	for i, fileDiff := range versionDiff.diffs {
+		files[i] = s.newCompareFile(fileDiff)
+	}
+
+	style := getStyle(r)
+	if err = templates.Compare(templates.CompareVars{
+		ID:       documentID,
+		From:     versionDiff.from,
+		To:       versionDiff.to,
+		Files:    files,
+		Versions: newTemplateVersions(versionDiff.versions),
+
+		Style: style.Name,
+		Theme: style.Theme,
+	}).Render(r.Context(), w); err != nil {
+		slog.ErrorContext(r.Context(), "failed to execute template", tint.Err(err))
+	}
+}
+
+func (s *Server) newCompareFile(d fileDiff) templates.CompareFile {
+	file := templates.CompareFile{
+		Status: string(d.status()),
+	}
+
+	var oldLines, newLines [][]chroma.Token
+	if d.old != nil {
+		file.Name = d.old.Name
+		oldLines = s.highlightLines(*d.old)
+	}
+	if d.new != nil {
+		file.Name = d.new.Name
+		newLines = s.highlightLines(*d.new)
+	}
+	if d.status() == DiffFileStatusRenamed {
+		file.OldName = d.old.Name
+	}
+
+	file.Rows = compareRows(d.hunks, oldLines, newLines)
+	return file
+}
+
+// highlightLines tokenises the whole file, so multi line tokens like comments are highlighted correctly, and splits the tokens into lines.
+func (s *Server) highlightLines(file database.File) [][]chroma.Token {
+	iterator, err := s.fileLexer(file).Tokenise(nil, file.Content)
+	if err != nil {
+		// lines without tokens are rendered as plain text
+		return nil
+	}
+	return chroma.SplitTokensIntoLines(iterator.Tokens())
+}
+
+// compareRows lays out the hunks side by side. Deleted and inserted lines of the same change are paired up
+// and get their changed words marked.
+func compareRows(hunks []diff.Hunk, oldLines [][]chroma.Token, newLines [][]chroma.Token) []templates.CompareRow {
+	var rows []templates.CompareRow
+	for _, hunk := range hunks {
+		rows = append(rows, templates.CompareRow{Hunk: hunk.Header()})
+
+		var deleted, inserted []compareLine
+		flush := func() {
+			for i := range max(len(deleted), len(inserted)) {
+				var (
+					row                templates.CompareRow
+					oldMarks, newMarks [][2]int
+				)
+				if i < len(deleted) && i < len(inserted) {
+					oldMarks, newMarks = lineChanges(deleted[i].text, inserted[i].text)
+				}
+				if i < len(deleted) {
+					row.Old = newCompareLine(oldLines, deleted[i], diff.OpDelete, oldMarks)
+				}
+				if i < len(inserted) {
+					row.New = newCompareLine(newLines, inserted[i], diff.OpInsert, newMarks)
+				}
+				rows = append(rows, row)
+			}
+			deleted, inserted = nil, nil
+		}
+
+		oldNumber, newNumber := hunk.AStart, hunk.BStart
+		for _, line := range hunk.Lines {
+			text := strings.TrimSuffix(line.Text, "\n")
+			switch line.Op {
+			case diff.OpDelete:
+				deleted = append(deleted, compareLine{number: oldNumber, text: text})
+				oldNumber++
+			case diff.OpInsert:
+				inserted = append(inserted, compareLine{number: newNumber, text: text})
+				newNumber++
+			default:
+				flush()
+				rows = append(rows, templates.CompareRow{
+					Old: newCompareLine(oldLines, compareLine{number: oldNumber, text: text}, diff.OpEqual, nil),
+					New: newCompareLine(newLines, compareLine{number: newNumber, text: text}, diff.OpEqual, nil),
+				})
+				oldNumber++
+				newNumber++
+			}
+		}
+		flush()
+	}
+	return rows
+}
+
+func newCompareLine(lines [][]chroma.Token, line compareLine, op diff.Op, marks [][2]int) templates.CompareLine {
+	return templates.CompareLine{
+		Number: line.number,
+		Op:     op.String(),
+		HTML:   highlightLine(lineTokens(lines, line.number, line.text), marks),
+	}
+}
+
+// lineTokens returns the tokens of the line with the given number or the plain text if the file could not be tokenised.
+func lineTokens(lines [][]chroma.Token, number int, text string) []chroma.Token {
+	if number <= len(lines) {
+		return lines[number-1]
+	}
+	return []chroma.Token{{Type: chroma.Text, Value: text}}
+}
+
+// lineChanges diffs the words of a deleted and an inserted line and returns the changed byte ranges of both lines.
+// Lines which have no word in common are left unmarked, since marking them completely does not help.
+func lineChanges(a string, b string) ([][2]int, [][2]int) {
+	aWords, bWords := diff.SplitWords(a), diff.SplitWords(b)
+	edits := diff.Diff(aWords, bWords)
+	if !slices.ContainsFunc(edits, func(edit diff.Edit) bool {
+		return edit.Op == diff.OpEqual && strings.TrimSpace(strings.Join(aWords[edit.AStart:edit.AEnd], "")) != ""
+	}) {
+		return nil, nil
+	}
+
+	aOffsets, bOffsets := wordOffsets(aWords), wordOffsets(bWords)
+	var aMarks, bMarks [][2]int
+	for _, edit := range edits {
+		switch edit.Op {
+		case diff.OpDelete:
+			aMarks = append(aMarks, [2]int{aOffsets[edit.AStart], aOffsets[edit.AEnd]})
+		case diff.OpInsert:
+			bMarks = append(bMarks, [2]int{bOffsets[edit.BStart], bOffsets[edit.BEnd]})
+		}
+	}
+	return aMarks, bMarks
+}
+
+// wordOffsets returns the byte offset of every word and the total length as last element.
+func wordOffsets(words []string) []int {
+	offsets := make([]int, len(words)+1)
+	for i, word := range words {
+		offsets[i+1] = offsets[i] + len(word)
+	}
+	return offsets
+}
+
+// highlightLine renders the tokens of a line as html with the same classes as the html formatter and wraps the marked byte ranges in <mark>.
+func highlightLine(tokens []chroma.Token, marks [][2]int) string {
+	var (
+		buf    strings.Builder
+		offset int
+	)
+	for _, token := range tokens {
+		value := strings.TrimSuffix(token.Value, "\n")
+		class := tokenClass(token.Type)
+		for value != "" {
+			end := len(value)
+			var marked bool
+			for _, mark := range marks {
+				if offset >= mark[0] && offset < mark[1] {
+					marked = true
+					end = min(end, mark[1]-offset)
+					break
+				}
+				if mark[0] > offset {
+					end = min(end, mark[0]-offset)
+				}
+			}
+
+			if marked {
+				buf.WriteString("<mark>")
+			}
+			if class != "" {
+				buf.WriteString(`<span class="` + class + `">` + html.EscapeString(value[:end]) + "</span>")
+			} else {
+				buf.WriteString(html.EscapeString(value[:end]))
+			}
+			if marked {
+				buf.WriteString("</mark>")
+			}
+			value = value[end:]
+			offset += end
+		}
+	}
+	return buf.String()
+}
+
+func tokenClass(tokenType chroma.TokenType) string {
+	for ; tokenType != 0; tokenType = tokenType.Parent() {
+		if class, ok := chroma.StandardTypes[tokenType]; ok {
+			if class == "" {
+				return ""
+			}
+			return "ch-" + class
+		}
+	}
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -27,6 +28,24 @@ func LoadConfig(cfgPath string) (Config, error) {
 		return Config{}, fmt.Errorf("failed to decode config file: %w", err)
 	}
 
+	if _, err = newSlugPattern(cfg.Slugs); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Retention != nil {
+		if err = cfg.Retention.Validate(); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if cfg.Storage != nil {
+		switch cfg.Storage.EvictionPolicy {
+		case database.EvictionPolicyOldest, database.EvictionPolicyLeastRecentlyViewed, database.EvictionPolicyLargest:
+		default:
+			return Config{}, fmt.Errorf("invalid storage eviction policy: %s", cfg.Storage.EvictionPolicy)
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -47,13 +66,20 @@ func defaultConfig() Config {
 			Debug:           false,
 			ExpireAfter:     0,
 			CleanupInterval: timex.Duration(time.Minute),
+			MaxDeltaChain:   10,
 			Path:            "gobin.db",
+			BusyTimeout:     timex.Duration(5 * time.Second),
 			Host:            "localhost",
 			Port:            5432,
 			Username:        "gobin",
 			Password:        "",
 			Database:        "gobin",
 			SSLMode:         "disable",
+			IDs: database.IDConfig{
+				Mode:       database.IDModeRandom,
+				MaxRetries: 5,
+			},
+			SnapshotInterval: timex.Duration(5 * time.Minute),
 		},
 		MaxDocumentSize:  0,
 		MaxHighlightSize: 0,
@@ -79,31 +105,41 @@ func defaultConfig() Config {
 			BackoffFactor: 2,
 			MaxBackoff:    timex.Duration(5 * time.Minute),
 		},
+		Slugs: SlugConfig{
+			Pattern:  "^[a-z0-9][a-z0-9-]{2,63}$",
+			Reserved: nil,
+		},
 		CustomStyles: "",
 		DefaultStyle: "onedark",
 	}
 }
 
 type Config struct {
-	Log              LogConfig        `toml:"log"`
-	Debug            bool             `toml:"debug"`
-	DevMode          bool             `toml:"dev_mode"`
-	ListenAddr       string           `toml:"listen_addr"`
-	HTTPTimeout      timex.Duration   `toml:"http_timeout"`
-	Database         database.Config  `toml:"database"`
-	MaxDocumentSize  int64            `toml:"max_document_size"`
-	MaxHighlightSize int              `toml:"max_highlight_size"`
-	RateLimit        *RateLimitConfig `toml:"rate_limit"`
-	JWTSecret        string           `toml:"jwt_secret"`
-	Preview          *PreviewConfig   `toml:"preview"`
-	Otel             *OtelConfig      `toml:"otel"`
-	Webhook          *WebhookConfig   `toml:"webhook"`
-	CustomStyles     string           `toml:"custom_styles"`
-	DefaultStyle     string           `toml:"default_style"`
+	Log              LogConfig                 `toml:"log"`
+	Debug            bool                      `toml:"debug"`
+	DevMode          bool                      `toml:"dev_mode"`
+	ListenAddr       string                    `toml:"listen_addr"`
+	HTTPTimeout      timex.Duration            `toml:"http_timeout"`
+	Database         database.Config           `toml:"database"`
+	MaxDocumentSize  int64                     `toml:"max_document_size"`
+	MaxHighlightSize int                       `toml:"max_highlight_size"`
+	RateLimit        *RateLimitConfig          `toml:"rate_limit"`
+	JWTSecret        string                    `toml:"jwt_secret"`
+	Preview          *PreviewConfig            `toml:"preview"`
+	Otel             *OtelConfig               `toml:"otel"`
+	Webhook          *WebhookConfig            `toml:"webhook"`
+	Storage          *StorageConfig            `toml:"storage"`
+	Retention        *database.RetentionPolicy `toml:"retention"`
+	Trash            *TrashConfig              `toml:"trash"`
+	Scrub            *ScrubConfig              `toml:"scrub"`
+	AdminToken       string                    `toml:"admin_token"`
+	Slugs            SlugConfig                `toml:"slugs"`
+	CustomStyles     string                    `toml:"custom_styles"`
+	DefaultStyle     string                    `toml:"default_style"`
 }
 
 func (c Config) String() string {
-	return fmt.Sprintf("\n Log: %s\n Debug: %t\n DevMode: %t\n ListenAddr: %s\n HTTPTimeout: %s\n Database: %s\n MaxDocumentSize: %d\n MaxHighlightSize: %d\n RateLimit: %s\n JWTSecret: %s\n Preview: %s\n Otel: %s\n Webhook: %s\n CustomStyles: %s\n DefaultStyle: %s\n",
+	return fmt.Sprintf("\n Log: %s\n Debug: %t\n DevMode: %t\n ListenAddr: %s\n HTTPTimeout: %s\n Database: %s\n MaxDocumentSize: %d\n MaxHighlightSize: %d\n RateLimit: %s\n JWTSecret: %s\n Preview: %s\n Otel: %s\n Webhook: %s\n Storage: %s\n Retention: %s\n Trash: %s\n Scrub: %s\n AdminToken: %s\n Slugs: %s\n CustomStyles: %s\n DefaultStyle: %s\n",
 		c.Log,
 		c.Debug,
 		c.DevMode,
@@ -117,6 +153,12 @@ func (c Config) String() string {
 		c.Preview,
 		c.Otel,
 		c.Webhook,
+		c.Storage,
+		c.Retention,
+		c.Trash,
+		c.Scrub,
+		strings.Repeat("*", len(c.AdminToken)),
+		c.Slugs,
 		c.CustomStyles,
 		c.DefaultStyle,
 	)
@@ -232,3 +274,55 @@ func (c WebhookConfig) String() string {
 		time.Duration(c.MaxBackoff),
 	)
 }
+
+type StorageConfig struct {
+	MaxSize        int64                   `toml:"max_size"`
+	EvictionPolicy database.EvictionPolicy `toml:"eviction_policy"`
+	BatchSize      int                     `toml:"batch_size"`
+}
+
+func (c StorageConfig) String() string {
+	return fmt.Sprintf("\n  MaxSize: %d\n  EvictionPolicy: %s\n  BatchSize: %d",
+		c.MaxSize,
+		c.EvictionPolicy,
+		c.BatchSize,
+	)
+}
+
+type TrashConfig struct {
+	GracePeriod timex.Duration `toml:"grace_period"`
+}
+
+func (c TrashConfig) String() string {
+	return fmt.Sprintf("\n  GracePeriod: %s", time.Duration(c.GracePeriod))
+}
+
+type ScrubConfig struct {
+	Interval  timex.Duration `toml:"interval"`
+	BatchSize int            `toml:"batch_size"`
+}
+
+func (c ScrubConfig) String() string {
+	return fmt.Sprintf("\n  Interval: %s\n  BatchSize: %d",
+		time.Duration(c.Interval),
+		c.BatchSize,
+	)
+}
+
+type SlugConfig struct {
+	Pattern  string   `toml:"pattern"`
+	Reserved []string `toml:"reserved"`
+}
+
+// ReservedSlugs returns the slugs which are always reserved and the configured ones.
+// Generated document ids skip them as well.
+func (c SlugConfig) ReservedSlugs() []string {
+	return append(slices.Clone(reservedSlugs), c.Reserved...)
+}
+
+func (c SlugConfig) String() string {
+	return fmt.Sprintf("\n  Pattern: %s\n  Reserved: %s",
+		c.Pattern,
+		strings.Join(c.Reserved, ", "),
+	)
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var ErrSlugTaken = errors.New("slug is already taken")
+
+// ResolveDocumentID returns the id of the document the alias points to.
+// If no alias with this name exists, the given id is returned as is.
+// It returns ErrDocumentDeleted if the document is in the trash.
+func (d *DB) ResolveDocumentID(ctx context.Context, id string) (string, error) {
+	documentID, deleted, err := d.resolveDocumentID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if deleted {
+		return "", ErrDocumentDeleted
+	}
+	return documentID, nil
+}
+
+// ResolveDocumentIDIncludingTrash is like ResolveDocumentID, but also resolves the aliases of documents in the trash.
+func (d *DB) ResolveDocumentIDIncludingTrash(ctx context.Context, id string) (string, error) {
+	documentID, _, err := d.resolveDocumentID(ctx, id)
+	return documentID, err
+}
+
+func (d *DB) resolveDocumentID(ctx context.Context, id string) (string, bool, error) {
+	var document struct {
+		ID        string     `db:"id"`
+		DeletedAt *time.Time `db:"deleted_at"`
+	}
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		return q.GetContext(ctx, &document, q.Rebind("SELECT id, deleted_at FROM documents WHERE id = ? UNION ALL SELECT d.id, d.deleted_at FROM document_aliases a JOIN documents d ON d.id = a.document_id WHERE a.alias = ?;"), id, id)
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return id, false, nil
+		}
+		return "", false, fmt.Errorf("failed to resolve document id: %w", err)
+	}
+	return document.ID, document.DeletedAt != nil, nil
+}
+
+func (d *DB) GetDocumentAliases(ctx context.Context, documentID string) ([]string, error) {
+	var aliases []string
+	if err := d.SelectContext(ctx, &aliases, d.Rebind("SELECT alias FROM document_aliases WHERE document_id = ? ORDER BY created_at;"), documentID); err != nil {
+		return nil, fmt.Errorf("failed to get document aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+func (d *DB) CreateDocumentAlias(ctx context.Context, documentID string, alias string) error {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var count int
+		if err := tx.GetContext(ctx, &count, tx.Rebind("SELECT COUNT(*) FROM documents WHERE id = ?;"), documentID); err != nil {
+			return err
+		}
+		if count == 0 {
+			return sql.ErrNoRows
+		}
+
+		if err := checkSlugAvailable(ctx, tx, alias); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO document_aliases (alias, document_id, created_at) VALUES (?, ?, ?);"), alias, documentID, time.Now()); err != nil {
+			if isUniqueViolation(err) {
+				return ErrSlugTaken
+			}
+			return err
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to create document alias: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) DeleteDocumentAlias(ctx context.Context, documentID string, alias string) error {
+	res, err := d.ExecContext(ctx, d.Rebind("DELETE FROM document_aliases WHERE document_id = ? AND alias = ?;"), documentID, alias)
+	if err != nil {
+		return fmt.Errorf("failed to delete document alias: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to delete document alias: %w", err)
+	} else if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SlugAvailable returns whether the slug is neither used as document id nor as alias.
+func (d *DB) SlugAvailable(ctx context.Context, slug string) (bool, error) {
+	if err := checkSlugAvailable(ctx, d, slug); err != nil {
+		if errors.Is(err, ErrSlugTaken) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check slug: %w", err)
+	}
+	return true, nil
+}
+
+// checkSlugAvailable returns ErrSlugTaken if the slug is already used as document id or alias.
+// Document ids and aliases share the same namespace, so a slug can never resolve to two documents.
+func checkSlugAvailable(ctx context.Context, q sqlx.ExtContext, slug string) error {
+	var count int
+	if err := sqlx.GetContext(ctx, q, &count, q.Rebind("SELECT (SELECT COUNT(*) FROM documents WHERE id = ?) + (SELECT COUNT(*) FROM document_aliases WHERE alias = ?);"), slug, slug); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSlugTaken
+	}
+	return nil
+}
@@ -0,0 +1,436 @@
+package database
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// BackupFormat identifies gobin backup archives.
+	BackupFormat = "gobin-backup"
+	// BackupFormatVersion is the version of the archive format written by WriteBackup.
+	// Archives with a newer version can't be restored.
+	BackupFormatVersion = 1
+
+	// backupBatchSize is how many document ids are read per query while exporting.
+	backupBatchSize = 100
+	// backupMaxLineSize is the max size of a single document in a backup archive.
+	backupMaxLineSize = 1 << 30
+)
+
+// BackupHeader is the first line of a backup archive, every following line is a BackupDocument.
+type BackupHeader struct {
+	Format       string    `json:"format"`
+	Version      int       `json:"version"`
+	CreatedAt    time.Time `json:"created_at"`
+	GobinVersion string    `json:"gobin_version"`
+	// IDSequence is the last value used to generate sequential document ids.
+	IDSequence int64 `json:"id_sequence"`
+}
+
+// BackupDocument is a document with all its versions, aliases and webhooks.
+type BackupDocument struct {
+	ID        string           `json:"id"`
+	CreatedAt time.Time        `json:"created_at"`
+	ViewedAt  *time.Time       `json:"viewed_at,omitempty"`
+	DeletedAt *time.Time       `json:"deleted_at,omitempty"`
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Versions are sorted from oldest to newest.
+	Versions []BackupVersion `json:"versions"`
+	Aliases  []BackupAlias   `json:"aliases,omitempty"`
+	Webhooks []BackupWebhook `json:"webhooks,omitempty"`
+}
+
+type BackupVersion struct {
+	Version int64        `json:"version"`
+	Message string       `json:"message,omitempty"`
+	Author  string       `json:"author,omitempty"`
+	Files   []BackupFile `json:"files"`
+}
+
+type BackupFile struct {
+	Name      string     `json:"name"`
+	Content   string     `json:"content"`
+	Language  string     `json:"language"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type BackupAlias struct {
+	Alias     string    `db:"alias" json:"alias"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type BackupWebhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// BackupStats counts what was written or restored.
+type BackupStats struct {
+	Documents int
+	Versions  int
+	Skipped   int
+}
+
+// WriteBackup streams all documents of the store to w as newline delimited JSON.
+func WriteBackup(ctx context.Context, store Store, w io.Writer, gobinVersion string) (BackupStats, error) {
+	var stats BackupStats
+
+	idSequence, err := store.GetIDSequence(ctx)
+	if err != nil {
+		return stats, err
+	}
+
+	encoder := json.NewEncoder(w)
+	if err = encoder.Encode(BackupHeader{
+		Format:       BackupFormat,
+		Version:      BackupFormatVersion,
+		CreatedAt:    time.Now(),
+		GobinVersion: gobinVersion,
+		IDSequence:   idSequence,
+	}); err != nil {
+		return stats, fmt.Errorf("failed to write backup header: %w", err)
+	}
+
+	err = store.ExportDocuments(ctx, func(document BackupDocument) error {
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("failed to write document %s: %w", document.ID, err)
+		}
+		stats.Documents++
+		stats.Versions += len(document.Versions)
+		return nil
+	})
+	return stats, err
+}
+
+// RestoreBackup imports all documents of the backup in r into the store.
+// Documents whose id or aliases are already taken are skipped, so an interrupted restore can be run again.
+func RestoreBackup(ctx context.Context, store Store, r io.Reader) (BackupStats, error) {
+	var stats BackupStats
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, backupMaxLineSize)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return stats, fmt.Errorf("failed to read backup header: %w", err)
+		}
+		return stats, errors.New("failed to read backup header: empty backup")
+	}
+
+	var header BackupHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return stats, fmt.Errorf("failed to decode backup header: %w", err)
+	}
+	if header.Format != BackupFormat {
+		return stats, fmt.Errorf("invalid backup format: %q", header.Format)
+	}
+	if header.Version > BackupFormatVersion {
+		return stats, fmt.Errorf("unsupported backup version: %d, max supported version is %d", header.Version, BackupFormatVersion)
+	}
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var document BackupDocument
+		if err := json.Unmarshal(scanner.Bytes(), &document); err != nil {
+			return stats, fmt.Errorf("failed to decode document %d: %w", stats.Documents+stats.Skipped+1, err)
+		}
+		if err := store.ImportDocument(ctx, document); err != nil {
+			if errors.Is(err, ErrSlugTaken) {
+				stats.Skipped++
+				continue
+			}
+			return stats, err
+		}
+		stats.Documents++
+		stats.Versions += len(document.Versions)
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if err := store.RestoreIDSequence(ctx, header.IDSequence); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+func newBackupFiles(files []File) []BackupFile {
+	backupFiles := make([]BackupFile, len(files))
+	for i, file := range files {
+		backupFiles[i] = BackupFile{
+			Name:      file.Name,
+			Content:   file.Content,
+			Language:  file.Language,
+			ExpiresAt: file.ExpiresAt,
+		}
+	}
+	return backupFiles
+}
+
+func (v BackupVersion) files(documentID string) []File {
+	files := make([]File, len(v.Files))
+	for i, file := range v.Files {
+		files[i] = File{
+			DocumentID:      documentID,
+			DocumentVersion: v.Version,
+			Name:            file.Name,
+			ContentHash:     hashContent(file.Content),
+			Content:         file.Content,
+			Language:        file.Language,
+			ExpiresAt:       file.ExpiresAt,
+			OrderIndex:      i,
+		}
+	}
+	return files
+}
+
+func (v BackupVersion) info() VersionInfo {
+	return VersionInfo{
+		Message: v.Message,
+		Author:  v.Author,
+	}
+}
+
+// sortedVersions returns the versions of the document from oldest to newest.
+func (b BackupDocument) sortedVersions() []BackupVersion {
+	return slices.SortedFunc(slices.Values(b.Versions), func(a BackupVersion, b BackupVersion) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+}
+
+func newBackupWebhook(webhook Webhook) BackupWebhook {
+	var events []string
+	if webhook.Events != "" {
+		events = strings.Split(webhook.Events, ",")
+	}
+	return BackupWebhook{
+		ID:     webhook.ID,
+		URL:    webhook.URL,
+		Secret: webhook.Secret,
+		Events: events,
+	}
+}
+
+type backupDocumentRow struct {
+	documentRetention
+	CreatedAt time.Time  `db:"created_at"`
+	ViewedAt  *time.Time `db:"viewed_at"`
+	DeletedAt *time.Time `db:"deleted_at"`
+}
+
+func (d *DB) GetIDSequence(ctx context.Context) (int64, error) {
+	var value int64
+	if err := d.GetContext(ctx, &value, d.Rebind("SELECT value FROM id_sequences WHERE name = ?;"), "documents"); err != nil {
+		return 0, fmt.Errorf("failed to get id sequence: %w", err)
+	}
+	return value, nil
+}
+
+// RestoreIDSequence raises the sequence for sequential document ids to value, so restored ids are not generated again.
+func (d *DB) RestoreIDSequence(ctx context.Context, value int64) error {
+	if _, err := d.ExecContext(ctx, d.Rebind("UPDATE id_sequences SET value = ? WHERE name = ? AND value < ?;"), value, "documents", value); err != nil {
+		return fmt.Errorf("failed to restore id sequence: %w", err)
+	}
+	return nil
+}
+
+// ExportDocuments calls fn for every document ordered by id. Each document is read on its own,
+// so changes made while exporting are either fully included in a document or not at all.
+func (d *DB) ExportDocuments(ctx context.Context, fn func(document BackupDocument) error) error {
+	var lastID string
+	for {
+		var documentIDs []string
+		if err := d.SelectContext(ctx, &documentIDs, d.Rebind("SELECT id FROM documents WHERE id > ? ORDER BY id LIMIT ?;"), lastID, backupBatchSize); err != nil {
+			return fmt.Errorf("failed to get documents for backup: %w", err)
+		}
+		if len(documentIDs) == 0 {
+			return nil
+		}
+
+		for _, documentID := range documentIDs {
+			document, err := d.exportDocument(ctx, documentID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					// deleted since the ids were read
+					continue
+				}
+				return fmt.Errorf("failed to export document %s: %w", documentID, err)
+			}
+			if err = fn(*document); err != nil {
+				return err
+			}
+		}
+		lastID = documentIDs[len(documentIDs)-1]
+	}
+}
+
+func (d *DB) exportDocument(ctx context.Context, documentID string) (*BackupDocument, error) {
+	var document *BackupDocument
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var row backupDocumentRow
+		if err := tx.GetContext(ctx, &row, tx.Rebind("SELECT id, created_at, viewed_at, deleted_at, retention_keep_last, retention_max_age, retention_thin_after FROM documents WHERE id = ?;"), documentID); err != nil {
+			return err
+		}
+
+		var files []File
+		if err := tx.SelectContext(ctx, &files, tx.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE document_id = ? ORDER BY document_version, order_index;"), documentID); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err := d.loadContents(ctx, tx, files); err != nil {
+			return err
+		}
+
+		var versions []Version
+		if err := tx.SelectContext(ctx, &versions, tx.Rebind("SELECT version, COALESCE(message, '') AS message, COALESCE(author, '') AS author FROM versions WHERE document_id = ?;"), documentID); err != nil {
+			return err
+		}
+		infos := make(map[int64]VersionInfo, len(versions))
+		for _, version := range versions {
+			infos[version.Version] = version.VersionInfo
+		}
+
+		var aliases []BackupAlias
+		if err := tx.SelectContext(ctx, &aliases, tx.Rebind("SELECT alias, created_at FROM document_aliases WHERE document_id = ? ORDER BY created_at;"), documentID); err != nil {
+			return err
+		}
+
+		var webhooks []Webhook
+		if err := tx.SelectContext(ctx, &webhooks, tx.Rebind("SELECT * FROM webhooks WHERE document_id = ?;"), documentID); err != nil {
+			return err
+		}
+
+		document = &BackupDocument{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			ViewedAt:  row.ViewedAt,
+			DeletedAt: row.DeletedAt,
+			Retention: row.policy(),
+			Aliases:   aliases,
+		}
+		for chunk := range chunkByVersion(files) {
+			info := infos[chunk[0].DocumentVersion]
+			document.Versions = append(document.Versions, BackupVersion{
+				Version: chunk[0].DocumentVersion,
+				Message: info.Message,
+				Author:  info.Author,
+				Files:   newBackupFiles(chunk),
+			})
+		}
+		for _, webhook := range webhooks {
+			document.Webhooks = append(document.Webhooks, newBackupWebhook(webhook))
+		}
+		return nil
+	})
+	return document, err
+}
+
+// chunkByVersion yields the consecutive files which belong to the same version.
+func chunkByVersion(files []File) func(yield func([]File) bool) {
+	return func(yield func([]File) bool) {
+		start := 0
+		for i := 1; i <= len(files); i++ {
+			if i < len(files) && files[i].DocumentVersion == files[start].DocumentVersion {
+				continue
+			}
+			if !yield(files[start:i]) {
+				return
+			}
+			start = i
+		}
+	}
+}
+
+// ImportDocument inserts a document from a backup with its id, versions, aliases and webhooks.
+// It returns ErrSlugTaken if the id or one of the aliases is already used.
+func (d *DB) ImportDocument(ctx context.Context, document BackupDocument) error {
+	versions := document.sortedVersions()
+	if len(versions) == 0 {
+		return fmt.Errorf("failed to import document %s: no versions", document.ID)
+	}
+	latestVersion := versions[len(versions)-1].Version
+
+	var keepLast, maxAge, thinAfter sql.NullInt64
+	if document.Retention != nil {
+		keepLast = sql.NullInt64{Int64: int64(document.Retention.KeepLast), Valid: true}
+		maxAge = sql.NullInt64{Int64: int64(document.Retention.MaxAge), Valid: true}
+		thinAfter = sql.NullInt64{Int64: int64(document.Retention.ThinAfter), Valid: true}
+	}
+
+	var blobs deltaBlobs
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := checkSlugAvailable(ctx, tx, document.ID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO documents (id, latest_version, created_at, viewed_at, deleted_at, retention_keep_last, retention_max_age, retention_thin_after) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"),
+			document.ID, latestVersion, document.CreatedAt, document.ViewedAt, document.DeletedAt, keepLast, maxAge, thinAfter,
+		); err != nil {
+			if isUniqueViolation(err) {
+				return ErrSlugTaken
+			}
+			return err
+		}
+
+		var previousFiles []File
+		for _, version := range versions {
+			files := version.files(document.ID)
+			if err := insertVersion(ctx, tx, document.ID, version.Version, version.info()); err != nil {
+				return err
+			}
+			if err := d.putContents(ctx, tx, files); err != nil {
+				return err
+			}
+			if len(files) > 0 {
+				if _, err := tx.NamedExecContext(ctx, "INSERT INTO files (name, document_id, document_version, content_hash, language, expires_at, order_index) VALUES (:name, :document_id, :document_version, :content_hash, :language, :expires_at, :order_index);", files); err != nil {
+					return err
+				}
+			}
+			if err := d.encodeDeltas(ctx, tx, previousFiles, files, &blobs); err != nil {
+				return err
+			}
+			previousFiles = files
+		}
+
+		for _, alias := range document.Aliases {
+			if err := checkSlugAvailable(ctx, tx, alias.Alias); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO document_aliases (alias, document_id, created_at) VALUES (?, ?, ?);"), alias.Alias, document.ID, alias.CreatedAt); err != nil {
+				if isUniqueViolation(err) {
+					return ErrSlugTaken
+				}
+				return err
+			}
+		}
+
+		for _, webhook := range document.Webhooks {
+			if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO webhooks (id, document_id, url, secret, events) VALUES (?, ?, ?, ?, ?);"), webhook.ID, document.ID, webhook.URL, webhook.Secret, strings.Join(webhook.Events, ",")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.cleanupDeltaBlobs(ctx, blobs, err)
+	if err != nil {
+		return fmt.Errorf("failed to import document %s: %w", document.ID, err)
+	}
+	d.publish(ctx, Event{Type: EventTypeCreate, DocumentID: document.ID, Version: latestVersion})
+	return nil
+}
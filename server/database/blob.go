@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/topi314/tint"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+type BlobType string
+
+const (
+	BlobTypeFilesystem BlobType = "filesystem"
+	BlobTypeS3         BlobType = "s3"
+)
+
+type BlobConfig struct {
+	Type    BlobType `toml:"type"`
+	MinSize int      `toml:"min_size"`
+
+	// Filesystem
+	Path string `toml:"path"`
+
+	// S3
+	Endpoint        string `toml:"endpoint"`
+	Region          string `toml:"region"`
+	Bucket          string `toml:"bucket"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	Secure          bool   `toml:"secure"`
+	PathStyle       bool   `toml:"path_style"`
+}
+
+func (c BlobConfig) String() string {
+	str := fmt.Sprintf("\n   Type: %s\n   MinSize: %d\n   ",
+		c.Type,
+		c.MinSize,
+	)
+	switch c.Type {
+	case BlobTypeFilesystem:
+		str += fmt.Sprintf("Path: %s", c.Path)
+	case BlobTypeS3:
+		str += fmt.Sprintf("Endpoint: %s\n   Region: %s\n   Bucket: %s\n   AccessKeyID: %s\n   SecretAccessKey: %s\n   Secure: %t\n   PathStyle: %t",
+			c.Endpoint,
+			c.Region,
+			c.Bucket,
+			c.AccessKeyID,
+			strings.Repeat("*", len(c.SecretAccessKey)),
+			c.Secure,
+			c.PathStyle,
+		)
+	default:
+		str += "Invalid blob type!"
+	}
+	return str
+}
+
+// BlobStore stores content bodies outside the database.
+// Implementations return ErrBlobNotFound if a blob does not exist.
+type BlobStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	Delete(ctx context.Context, key string) error
+}
+
+func newBlobStore(ctx context.Context, cfg *BlobConfig) (BlobStore, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	switch cfg.Type {
+	case BlobTypeFilesystem:
+		return newFilesystemBlobStore(cfg.Path)
+	case BlobTypeS3:
+		return newS3BlobStore(ctx, *cfg)
+	default:
+		return nil, errors.New("invalid blob type, must be one of: filesystem, s3")
+	}
+}
+
+// blobKey returns the key the stored data of a content is saved under.
+// The key depends on the stored data, because the same content can be stored in full or as delta.
+func blobKey(hash string, data []byte) string {
+	return hash + "-" + hashContent(string(data))[:16]
+}
+
+// storeContent saves data in the blob store if one is configured and data is large enough.
+// It returns the data which should be saved in the contents table and the blob key if the data was saved in the blob store.
+func (d *DB) storeContent(ctx context.Context, hash string, data []byte) ([]byte, *string, error) {
+	if d.blobs == nil || len(data) < d.cfg.Blob.MinSize {
+		return data, nil, nil
+	}
+
+	key := blobKey(hash, data)
+	if err := d.blobs.Put(ctx, key, data); err != nil {
+		return nil, nil, fmt.Errorf("failed to put blob %s: %w", key, err)
+	}
+	return []byte{}, &key, nil
+}
+
+func (d *DB) loadBlob(ctx context.Context, key string) ([]byte, error) {
+	if d.blobs == nil {
+		return nil, fmt.Errorf("content is stored in blob %s but no blob store is configured", key)
+	}
+	data, err := d.blobs.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// deleteBlobs removes blobs which are no longer referenced.
+// Failures are only logged, as the contents referencing them are already gone.
+func (d *DB) deleteBlobs(ctx context.Context, keys []string) {
+	if d.blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := d.blobs.Delete(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "failed to delete blob", slog.String("key", key), tint.Err(err))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var _ BlobStore = (*filesystemBlobStore)(nil)
+
+func newFilesystemBlobStore(path string) (*filesystemBlobStore, error) {
+	if path == "" {
+		return nil, errors.New("blob path must be set")
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &filesystemBlobStore{path: path}, nil
+}
+
+// filesystemBlobStore stores blobs in a directory tree sharded by the first characters of the key.
+type filesystemBlobStore struct {
+	path string
+}
+
+func (s *filesystemBlobStore) filePath(key string) string {
+	if len(key) < 4 {
+		return filepath.Join(s.path, key)
+	}
+	return filepath.Join(s.path, key[:2], key[2:4], key)
+}
+
+func (s *filesystemBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.filePath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return data, err
+}
+
+func (s *filesystemBlobStore) Put(_ context.Context, key string, data []byte) error {
+	path := s.filePath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so readers never see partial blobs
+	file, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (s *filesystemBlobStore) Delete(_ context.Context, key string) error {
+	if err := os.Remove(s.filePath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/topi314/tint"
+
+	"github.com/topi314/gobin/v2/internal/timex"
+)
+
+const (
+	CompressionNone = ""
+	CompressionZstd = "zstd"
+)
+
+type CompressionConfig struct {
+	Threshold           int            `toml:"threshold"`
+	Level               int            `toml:"level"`
+	RecompressInterval  timex.Duration `toml:"recompress_interval"`
+	RecompressBatchSize int            `toml:"recompress_batch_size"`
+}
+
+func (c CompressionConfig) String() string {
+	return fmt.Sprintf("\n   Threshold: %d\n   Level: %d\n   RecompressInterval: %s\n   RecompressBatchSize: %d",
+		c.Threshold,
+		c.Level,
+		time.Duration(c.RecompressInterval),
+		c.RecompressBatchSize,
+	)
+}
+
+func newCompressor(cfg *CompressionConfig) (*compressor, error) {
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	c := &compressor{
+		cfg:     cfg,
+		decoder: decoder,
+	}
+	if cfg == nil {
+		return c, nil
+	}
+
+	level := zstd.SpeedDefault
+	if cfg.Level > 0 {
+		level = zstd.EncoderLevelFromZstd(cfg.Level)
+	}
+	c.encoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		decoder.Close()
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	return c, nil
+}
+
+// compressor compresses contents above the configured threshold.
+// Decompression is always possible, even if compression is disabled.
+type compressor struct {
+	cfg     *CompressionConfig
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c *compressor) compress(data []byte) ([]byte, string) {
+	if c.encoder == nil || len(data) <= c.cfg.Threshold {
+		return data, CompressionNone
+	}
+	return c.encoder.EncodeAll(data, nil), CompressionZstd
+}
+
+func (c *compressor) decompress(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case CompressionNone:
+		return data, nil
+	case CompressionZstd:
+		return c.decoder.DecodeAll(data, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", compression)
+	}
+}
+
+func (c *compressor) close() {
+	if c.encoder != nil {
+		_ = c.encoder.Close()
+	}
+	c.decoder.Close()
+}
+
+// recompress periodically compresses stored contents which are above the threshold but not compressed yet.
+func (d *DB) recompress(ctx context.Context) {
+	interval := time.Duration(d.cfg.Compression.RecompressInterval)
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.recompressContents(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				slog.ErrorContext(ctx, "failed to recompress contents", tint.Err(err))
+			}
+		}
+	}
+}
+
+func (d *DB) recompressContents(ctx context.Context) error {
+	batchSize := d.cfg.Compression.RecompressBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	var (
+		total    int
+		lastHash string
+	)
+	for {
+		var contents []Content
+		// encrypted contents are compared by the length of their plaintext, the same length compress uses
+		if err := d.SelectContext(ctx, &contents, d.Rebind("SELECT hash, content, key_id, data_key FROM contents WHERE hash > ? AND compression = ? AND length(content) > ? + CASE WHEN key_id IS NULL THEN 0 ELSE ? END ORDER BY hash LIMIT ?;"), lastHash, CompressionNone, d.cfg.Compression.Threshold, sealOverhead, batchSize); err != nil {
+			return fmt.Errorf("failed to get uncompressed contents: %w", err)
+		}
+		if len(contents) == 0 {
+			break
+		}
+		lastHash = contents[len(contents)-1].Hash
+
+		for _, content := range contents {
+			data, err := d.decryptContent(content)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt content %s: %w", content.Hash, err)
+			}
+			data, compression := d.compressor.compress(data)
+			if compression == CompressionNone {
+				// keep the stored content as it is instead of encrypting it again with a new data key
+				continue
+			}
+			data, keyID, dataKey, err := d.encryptContent(content.Hash, data)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt content %s: %w", content.Hash, err)
+			}
+			// only update the content if it has not been changed in the meantime
+			if _, err = d.ExecContext(ctx, d.Rebind("UPDATE contents SET content = ?, compression = ?, key_id = ?, data_key = ?, size = ? WHERE hash = ? AND compression = ? AND content = ?;"), data, compression, keyID, dataKey, len(data), content.Hash, CompressionNone, content.Content); err != nil {
+				return fmt.Errorf("failed to update compressed content: %w", err)
+			}
+			total++
+		}
+	}
+
+	if total > 0 {
+		slog.DebugContext(ctx, "recompressed contents", slog.Int("count", total))
+	}
+	return nil
+}
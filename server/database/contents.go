@@ -0,0 +1,331 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/topi314/tint"
+
+	"github.com/topi314/gobin/v2/internal/delta"
+)
+
+const (
+	// orphanedContentGracePeriod is how long unreferenced contents are kept before they are removed.
+	// This prevents deleting contents which are about to be referenced by a concurrent write.
+	orphanedContentGracePeriod = time.Hour
+
+	// maxDeltaDepth protects against broken delta chains when resolving contents.
+	maxDeltaDepth = 1024
+)
+
+// Content is a stored file body keyed by the sha256 hash of its plain content.
+// If BaseHash is set, Content is a delta which has to be applied to the content of BaseHash.
+// ChainLength is the longest delta chain ending in a full content.
+// Compression is the algorithm the stored content is compressed with.
+// If BlobKey is set, the stored content is kept in the blob store instead of the contents table.
+// If KeyID is set, the stored content is encrypted with DataKey, which itself is encrypted with the key KeyID.
+type Content struct {
+	Hash        string    `db:"hash"`
+	Content     []byte    `db:"content"`
+	BaseHash    *string   `db:"base_hash"`
+	ChainLength int       `db:"chain_length"`
+	Compression string    `db:"compression"`
+	BlobKey     *string   `db:"blob_key"`
+	KeyID       *string   `db:"key_id"`
+	DataKey     []byte    `db:"data_key"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// putContents stores the content of the files keyed by their hash and sets the ContentHash of each file.
+// Contents which are already stored are only touched to protect them from being removed as orphans.
+func (d *DB) putContents(ctx context.Context, tx *sqlx.Tx, files []File) error {
+	now := time.Now()
+	for i := range files {
+		files[i].ContentHash = hashContent(files[i].Content)
+
+		res, err := tx.ExecContext(ctx, tx.Rebind("UPDATE contents SET updated_at = ? WHERE hash = ?;"), now, files[i].ContentHash)
+		if err != nil {
+			return fmt.Errorf("failed to touch content: %w", err)
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows > 0 {
+			continue
+		}
+
+		data, compression := d.compressor.compress([]byte(files[i].Content))
+		data, keyID, dataKey, err := d.encryptContent(files[i].ContentHash, data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt content: %w", err)
+		}
+		size := len(data)
+		data, blobKey, err := d.storeContent(ctx, files[i].ContentHash, data)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, tx.Rebind("INSERT INTO contents (hash, content, compression, blob_key, key_id, data_key, size, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?) "+d.upsertClause("hash", "updated_at")+";"), files[i].ContentHash, data, compression, blobKey, keyID, dataKey, size, now); err != nil {
+			return fmt.Errorf("failed to insert content: %w", err)
+		}
+	}
+	return nil
+}
+
+// getContents returns the decrypted and decompressed contents with the given hashes and all contents they are based on.
+func (d *DB) getContents(ctx context.Context, q sqlx.ExtContext, hashes []string) (map[string]Content, error) {
+	contents := make(map[string]Content, len(hashes))
+	for len(hashes) > 0 {
+		query, args, err := sqlx.In("SELECT hash, content, base_hash, chain_length, compression, blob_key, key_id, data_key FROM contents WHERE hash IN (?);", hashes)
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []Content
+		if err = sqlx.SelectContext(ctx, q, &rows, q.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed to get contents: %w", err)
+		}
+
+		hashes = nil
+		for _, content := range rows {
+			if content.BlobKey != nil {
+				if content.Content, err = d.loadBlob(ctx, *content.BlobKey); err != nil {
+					return nil, err
+				}
+			}
+			if content.Content, err = d.decryptContent(content); err != nil {
+				return nil, fmt.Errorf("failed to decrypt content %s: %w", content.Hash, err)
+			}
+			if content.Content, err = d.compressor.decompress(content.Content, content.Compression); err != nil {
+				return nil, fmt.Errorf("failed to decompress content %s: %w", content.Hash, err)
+			}
+			contents[content.Hash] = content
+			if content.BaseHash == nil {
+				continue
+			}
+			if _, ok := contents[*content.BaseHash]; !ok {
+				hashes = append(hashes, *content.BaseHash)
+			}
+		}
+	}
+	return contents, nil
+}
+
+// loadContents fills the Content of the files from the contents table.
+func (d *DB) loadContents(ctx context.Context, q sqlx.ExtContext, files []File) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, 0, len(files))
+	for _, file := range files {
+		hashes = append(hashes, file.ContentHash)
+	}
+
+	contents, err := d.getContents(ctx, q, hashes)
+	if err != nil {
+		return err
+	}
+
+	resolved := make(map[string][]byte, len(files))
+	for i := range files {
+		content, err := resolveContent(contents, resolved, files[i].ContentHash, 0)
+		if err != nil {
+			return fmt.Errorf("failed to resolve content of file %s of document %s: %w", files[i].Name, files[i].DocumentID, err)
+		}
+		files[i].Content = string(content)
+	}
+	return nil
+}
+
+func resolveContent(contents map[string]Content, resolved map[string][]byte, hash string, depth int) ([]byte, error) {
+	if data, ok := resolved[hash]; ok {
+		return data, nil
+	}
+
+	content, ok := contents[hash]
+	if !ok {
+		return nil, fmt.Errorf("missing content %s", hash)
+	}
+	if content.BaseHash == nil {
+		resolved[hash] = content.Content
+		return content.Content, nil
+	}
+
+	if depth >= maxDeltaDepth {
+		return nil, fmt.Errorf("delta chain of content %s is too long", hash)
+	}
+	base, err := resolveContent(contents, resolved, *content.BaseHash, depth+1)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := delta.Apply(base, content.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply delta of content %s: %w", hash, err)
+	}
+	resolved[hash] = data
+	return data, nil
+}
+
+// deltaBlobs collects the blobs touched by encodeDeltas, they have to be cleaned up once the transaction is done.
+type deltaBlobs struct {
+	// stale blobs have been replaced by deltas and should be deleted once the transaction is committed
+	stale []string
+	// written blobs hold the new deltas and should be deleted if the transaction is rolled back
+	written []string
+}
+
+// cleanupDeltaBlobs deletes the stale blobs if the transaction was committed or the written blobs if it was rolled back.
+func (d *DB) cleanupDeltaBlobs(ctx context.Context, blobs deltaBlobs, err error) {
+	if err != nil {
+		d.deleteBlobs(ctx, blobs.written)
+		return
+	}
+	d.deleteBlobs(ctx, blobs.stale)
+}
+
+// encodeDeltas stores the contents of the previous version as deltas against the contents of the new version.
+// Files are matched by name. The touched blobs are collected in blobs.
+func (d *DB) encodeDeltas(ctx context.Context, tx *sqlx.Tx, previousFiles []File, files []File, blobs *deltaBlobs) error {
+	if d.cfg.MaxDeltaChain <= 0 {
+		return nil
+	}
+
+	hashes := make(map[string]string, len(files))
+	for _, file := range files {
+		hashes[file.Name] = file.ContentHash
+	}
+
+	for _, previousFile := range previousFiles {
+		hash, ok := hashes[previousFile.Name]
+		if !ok || hash == previousFile.ContentHash {
+			continue
+		}
+		if err := d.encodeDelta(ctx, tx, previousFile.ContentHash, hash, blobs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeDelta replaces the content of targetHash with a delta against baseHash.
+// Both contents have to be stored in full. If the resulting delta chain would exceed Config.MaxDeltaChain,
+// the target is kept in full as a snapshot. If the target has been replaced by a delta concurrently, it is left alone.
+func (d *DB) encodeDelta(ctx context.Context, tx *sqlx.Tx, targetHash string, baseHash string, blobs *deltaBlobs) error {
+	contents, err := d.getContents(ctx, tx, []string{targetHash, baseHash})
+	if err != nil {
+		return err
+	}
+
+	target, ok := contents[targetHash]
+	if !ok || target.BaseHash != nil {
+		return nil
+	}
+	base, ok := contents[baseHash]
+	if !ok || base.BaseHash != nil {
+		return nil
+	}
+
+	chainLength := target.ChainLength + 1
+	if chainLength > d.cfg.MaxDeltaChain {
+		return nil
+	}
+
+	data := delta.Encode(base.Content, target.Content)
+	if len(data) >= len(target.Content) {
+		return nil
+	}
+
+	data, compression := d.compressor.compress(data)
+	data, keyID, dataKey, err := d.encryptContent(targetHash, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt content delta: %w", err)
+	}
+	size := len(data)
+	data, blobKey, err := d.storeContent(ctx, targetHash, data)
+	if err != nil {
+		return err
+	}
+	if blobKey != nil {
+		blobs.written = append(blobs.written, *blobKey)
+	}
+	res, err := tx.ExecContext(ctx, tx.Rebind("UPDATE contents SET content = ?, compression = ?, blob_key = ?, key_id = ?, data_key = ?, size = ?, base_hash = ?, chain_length = 0 WHERE hash = ? AND base_hash IS NULL;"), data, compression, blobKey, keyID, dataKey, size, baseHash, targetHash)
+	if err != nil {
+		return fmt.Errorf("failed to update content delta: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		// another writer stored the target as delta in the meantime, so the new delta is not needed
+		if blobKey != nil {
+			blobs.written = blobs.written[:len(blobs.written)-1]
+			d.deleteUnusedBlob(ctx, tx, targetHash, *blobKey)
+		}
+		return nil
+	}
+	if _, err = tx.ExecContext(ctx, tx.Rebind("UPDATE contents SET chain_length = ? WHERE hash = ? AND chain_length < ?;"), chainLength, baseHash, chainLength); err != nil {
+		return fmt.Errorf("failed to update content chain length: %w", err)
+	}
+	if target.BlobKey != nil {
+		blobs.stale = append(blobs.stale, *target.BlobKey)
+	}
+	return nil
+}
+
+// deleteUnusedBlob deletes a blob which was written for a content, unless the content references the same blob,
+// which happens if another writer stored the exact same data.
+func (d *DB) deleteUnusedBlob(ctx context.Context, tx *sqlx.Tx, hash string, key string) {
+	var current sql.NullString
+	if err := tx.GetContext(ctx, &current, tx.Rebind("SELECT blob_key FROM contents WHERE hash = ?;"), hash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.ErrorContext(ctx, "failed to get content blob key", slog.String("hash", hash), tint.Err(err))
+		return
+	}
+	if current.Valid && current.String == key {
+		return
+	}
+	d.deleteBlobs(ctx, []string{key})
+}
+
+// orphanedContentsCondition matches contents older than the bound time which are neither referenced by a file nor used as base of a delta.
+// The bases are selected through a derived table, as MySQL does not allow referencing the table which is deleted from in a subquery.
+const orphanedContentsCondition = "updated_at < ? AND hash NOT IN (SELECT content_hash FROM files) AND hash NOT IN (SELECT base_hash FROM (SELECT DISTINCT base_hash FROM contents WHERE base_hash IS NOT NULL) bases)"
+
+// deleteOrphanedContents removes contents which are no longer referenced by any file or delta.
+func (d *DB) deleteOrphanedContents(ctx context.Context) error {
+	olderThan := time.Now().Add(-orphanedContentGracePeriod)
+	if _, err := d.ExecContext(ctx, d.Rebind("DELETE FROM contents WHERE blob_key IS NULL AND "+orphanedContentsCondition+";"), olderThan); err != nil {
+		return fmt.Errorf("failed to delete orphaned contents: %w", err)
+	}
+
+	// contents in the blob store are deleted one by one, so their blobs are only removed if the row is really gone
+	var contents []Content
+	if err := d.SelectContext(ctx, &contents, d.Rebind("SELECT hash, blob_key FROM contents WHERE blob_key IS NOT NULL AND "+orphanedContentsCondition+";"), olderThan); err != nil {
+		return fmt.Errorf("failed to get orphaned blob contents: %w", err)
+	}
+
+	var blobKeys []string
+	for _, content := range contents {
+		res, err := d.ExecContext(ctx, d.Rebind("DELETE FROM contents WHERE hash = ? AND blob_key = ? AND "+orphanedContentsCondition+";"), content.Hash, *content.BlobKey, olderThan)
+		if err != nil {
+			return fmt.Errorf("failed to delete orphaned content: %w", err)
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows > 0 {
+			blobKeys = append(blobKeys, *content.BlobKey)
+		}
+	}
+	d.deleteBlobs(ctx, blobKeys)
+	return nil
+}
@@ -2,15 +2,25 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/XSAM/otelsql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jackc/pgx/v5/tracelog"
@@ -18,75 +28,441 @@ import (
 	"github.com/topi314/gomigrate"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/semconv/v1.25.0"
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 
 	"github.com/topi314/gobin/v2/internal/timex"
 )
 
-var chars = []rune("abcdefghijklmnopqrstuvwxyz0123456789")
+func init() {
+	// sha256 is used by the sqlite migrations to hash existing file contents
+	sqlite.MustRegisterDeterministicScalarFunction("sha256", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch arg := args[0].(type) {
+		case string:
+			return hashContent(arg), nil
+		case []byte:
+			return hashContent(string(arg)), nil
+		default:
+			return nil, fmt.Errorf("sha256: unsupported argument type %T", arg)
+		}
+	})
+}
 
 type Type string
 
 const (
 	TypePostgres Type = "postgres"
 	TypeSQLite   Type = "sqlite"
+	TypeMySQL    Type = "mysql"
+	TypeMemory   Type = "memory"
 )
 
 type Config struct {
-	Type            Type           `toml:"type"`
-	Debug           bool           `toml:"debug"`
-	ExpireAfter     timex.Duration `toml:"expire_after"`
-	CleanupInterval timex.Duration `toml:"cleanup_interval"`
+	Type            Type                  `toml:"type"`
+	Debug           bool                  `toml:"debug"`
+	ExpireAfter     timex.Duration        `toml:"expire_after"`
+	CleanupInterval timex.Duration        `toml:"cleanup_interval"`
+	MaxDeltaChain   int                   `toml:"max_delta_chain"`
+	Compression     *CompressionConfig    `toml:"compression"`
+	Blob            *BlobConfig           `toml:"blob"`
+	Encryption      *EncryptionConfig     `toml:"encryption"`
+	IDs             IDConfig              `toml:"ids"`
+	LeaderElection  *LeaderElectionConfig `toml:"leader_election"`
+	ReadReplicas    *ReadReplicaConfig    `toml:"read_replicas"`
+
+	// Connection pool
+	MaxOpenConns    int            `toml:"max_open_conns"`
+	MaxIdleConns    int            `toml:"max_idle_conns"`
+	ConnMaxLifetime timex.Duration `toml:"conn_max_lifetime"`
+	ConnMaxIdleTime timex.Duration `toml:"conn_max_idle_time"`
 
 	// SQLite
-	Path string `toml:"path"`
+	Path        string         `toml:"path"`
+	JournalMode string         `toml:"journal_mode"`
+	BusyTimeout timex.Duration `toml:"busy_timeout"`
+	Synchronous string         `toml:"synchronous"`
+
+	// Memory
+	MaxSize          int64          `toml:"max_size"`
+	Snapshot         string         `toml:"snapshot"`
+	SnapshotInterval timex.Duration `toml:"snapshot_interval"`
+
+	// PostgreSQL & MySQL
+	DSN              string         `toml:"dsn"`
+	Host             string         `toml:"host"`
+	Port             int            `toml:"port"`
+	Username         string         `toml:"username"`
+	Password         string         `toml:"password"`
+	Database         string         `toml:"database"`
+	SSLMode          string         `toml:"ssl_mode"`
+	SSLRootCert      string         `toml:"ssl_root_cert"`
+	SSLCert          string         `toml:"ssl_cert"`
+	SSLKey           string         `toml:"ssl_key"`
+	StatementTimeout timex.Duration `toml:"statement_timeout"`
 
 	// PostgreSQL
-	Host     string `toml:"host"`
-	Port     int    `toml:"port"`
-	Username string `toml:"username"`
-	Password string `toml:"password"`
-	Database string `toml:"database"`
-	SSLMode  string `toml:"ssl_mode"`
+	SearchPath string `toml:"search_path"`
 }
 
 func (c Config) String() string {
-	str := fmt.Sprintf("\n  Type: %s\n  Debug: %t\n  ExpireAfter: %s\n  CleanupInterval: %s\n  ",
+	str := fmt.Sprintf("\n  Type: %s\n  Debug: %t\n  ExpireAfter: %s\n  CleanupInterval: %s\n  MaxDeltaChain: %d\n  Compression: %s\n  Blob: %s\n  Encryption: %s\n  IDs: %s\n  LeaderElection: %s\n  ReadReplicas: %s\n  ",
 		c.Type,
 		c.Debug,
 		time.Duration(c.ExpireAfter),
 		time.Duration(c.CleanupInterval),
+		c.MaxDeltaChain,
+		c.Compression,
+		c.Blob,
+		c.Encryption,
+		c.IDs,
+		c.LeaderElection,
+		c.ReadReplicas,
 	)
+	if c.Type != TypeMemory {
+		str += fmt.Sprintf("MaxOpenConns: %d\n  MaxIdleConns: %d\n  ConnMaxLifetime: %s\n  ConnMaxIdleTime: %s\n  ",
+			c.MaxOpenConns,
+			c.MaxIdleConns,
+			time.Duration(c.ConnMaxLifetime),
+			time.Duration(c.ConnMaxIdleTime),
+		)
+	}
 	switch c.Type {
-	case TypePostgres:
-		str += fmt.Sprintf("Host: %s\n  Port: %d\n  Username: %s\n  Password: %s\n  Database: %s\n  SSLMode: %s",
+	case TypePostgres, TypeMySQL:
+		str += fmt.Sprintf("DSN: %s\n  Host: %s\n  Port: %d\n  Username: %s\n  Password: %s\n  Database: %s\n  SSLMode: %s\n  SSLRootCert: %s\n  SSLCert: %s\n  SSLKey: %s\n  StatementTimeout: %s",
+			strings.Repeat("*", len(c.DSN)),
 			c.Host,
 			c.Port,
 			c.Username,
 			strings.Repeat("*", len(c.Password)),
 			c.Database,
 			c.SSLMode,
+			c.SSLRootCert,
+			c.SSLCert,
+			c.SSLKey,
+			time.Duration(c.StatementTimeout),
 		)
+		if c.Type == TypePostgres {
+			str += fmt.Sprintf("\n  SearchPath: %s", c.SearchPath)
+		}
 	case TypeSQLite:
-		str += fmt.Sprintf("Path: %s", c.Path)
+		str += fmt.Sprintf("Path: %s\n  JournalMode: %s\n  BusyTimeout: %s\n  Synchronous: %s",
+			c.Path,
+			c.JournalMode,
+			time.Duration(c.BusyTimeout),
+			c.Synchronous,
+		)
+	case TypeMemory:
+		str += fmt.Sprintf("MaxSize: %d\n  Snapshot: %s\n  SnapshotInterval: %s", c.MaxSize, c.Snapshot, time.Duration(c.SnapshotInterval))
 	default:
 		str += "Invalid database type!"
 	}
 	return str
 }
 
+// PostgresDataSourceName returns the configured DSN, or builds a key/value DSN from the connection settings if none is set.
 func (c Config) PostgresDataSourceName() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		c.Host,
-		c.Port,
-		c.Username,
-		c.Password,
-		c.Database,
-		c.SSLMode,
+	if c.DSN != "" {
+		return c.DSN
+	}
+
+	params := []string{
+		"host=" + quotePostgresValue(c.Host),
+		"port=" + strconv.Itoa(c.Port),
+		"user=" + quotePostgresValue(c.Username),
+		"password=" + quotePostgresValue(c.Password),
+		"dbname=" + quotePostgresValue(c.Database),
+		"sslmode=" + quotePostgresValue(c.SSLMode),
+	}
+	if c.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quotePostgresValue(c.SSLRootCert))
+	}
+	if c.SSLCert != "" {
+		params = append(params, "sslcert="+quotePostgresValue(c.SSLCert))
+	}
+	if c.SSLKey != "" {
+		params = append(params, "sslkey="+quotePostgresValue(c.SSLKey))
+	}
+	return strings.Join(params, " ")
+}
+
+// quotePostgresValue quotes a value of a key/value DSN if it is empty or contains spaces, quotes or backslashes.
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(value) + "'"
+}
+
+// PostgresConnConfig parses the DSN and applies the settings which are not part of it.
+func (c Config) PostgresConnConfig() (*pgx.ConnConfig, error) {
+	return c.postgresConnConfig(c.PostgresDataSourceName())
+}
+
+func (c Config) postgresConnConfig(dsn string) (*pgx.ConnConfig, error) {
+	pgCfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres dsn: %w", err)
+	}
+	if c.SearchPath != "" {
+		pgCfg.RuntimeParams["search_path"] = c.SearchPath
+	}
+	if c.StatementTimeout > 0 {
+		pgCfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(time.Duration(c.StatementTimeout).Milliseconds(), 10)
+	}
+	return pgCfg, nil
+}
+
+// registerPostgresConnConfig registers the connection config for the DSN with the pgx driver and returns the name to open it with.
+func (c Config) registerPostgresConnConfig(dsn string) (string, error) {
+	pgCfg, err := c.postgresConnConfig(dsn)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Debug {
+		pgCfg.Tracer = &tracelog.TraceLog{
+			Logger: tracelog.LoggerFunc(func(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+				args := make([]any, 0, len(data))
+				for k, v := range data {
+					args = append(args, slog.Any(k, v))
+				}
+				slog.DebugContext(ctx, msg, slog.Group("data", args...))
+			}),
+			LogLevel: tracelog.LogLevelDebug,
+		}
+	}
+	return stdlib.RegisterConnConfig(pgCfg), nil
+}
+
+// MySQLDataSourceName returns the configured DSN, or builds one from the connection settings if none is set.
+// The DSN can either be in the go-sql-driver format or a mysql:// URL.
+func (c Config) MySQLDataSourceName() (string, error) {
+	mysqlCfg, err := c.mysqlConfig()
+	if err != nil {
+		return "", err
+	}
+	return mysqlCfg.FormatDSN(), nil
+}
+
+// withMySQLStatementTimeout adds the session variable limiting the execution time of statements to the DSN.
+// MySQL and MariaDB name it differently, so the server is asked which one it is first.
+func withMySQLStatementTimeout(ctx context.Context, dsn string, timeout time.Duration) (string, error) {
+	mysqlCfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse mysql dsn: %w", err)
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return "", fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var version string
+	if err = db.QueryRowContext(ctx, "SELECT VERSION();").Scan(&version); err != nil {
+		return "", fmt.Errorf("failed to get database version: %w", err)
+	}
+
+	if mysqlCfg.Params == nil {
+		mysqlCfg.Params = make(map[string]string)
+	}
+	if strings.Contains(version, "MariaDB") {
+		// limits all statements, in seconds
+		mysqlCfg.Params["max_statement_time"] = strconv.FormatFloat(timeout.Seconds(), 'f', -1, 64)
+	} else {
+		// only limits SELECT statements, mysql has no timeout for other statements
+		mysqlCfg.Params["max_execution_time"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+	return mysqlCfg.FormatDSN(), nil
+}
+
+// MySQLMigrationDataSourceName returns the DSN used to migrate the database.
+// Unlike MySQLDataSourceName it allows multiple statements per query, as migrations are executed as a whole.
+func (c Config) MySQLMigrationDataSourceName() (string, error) {
+	mysqlCfg, err := c.mysqlConfig()
+	if err != nil {
+		return "", err
+	}
+	mysqlCfg.MultiStatements = true
+	return mysqlCfg.FormatDSN(), nil
+}
+
+func (c Config) mysqlConfig() (*mysql.Config, error) {
+	var (
+		mysqlCfg *mysql.Config
+		err      error
 	)
+	if c.DSN != "" {
+		mysqlCfg, err = parseMySQLDataSourceName(c.DSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mysql dsn: %w", err)
+		}
+	} else {
+		mysqlCfg = mysql.NewConfig()
+		mysqlCfg.Net = "tcp"
+		mysqlCfg.Addr = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+		mysqlCfg.User = c.Username
+		mysqlCfg.Passwd = c.Password
+		mysqlCfg.DBName = c.Database
+		if mysqlCfg.TLSConfig, err = c.mysqlTLSConfig(); err != nil {
+			return nil, err
+		}
+	}
+	mysqlCfg.ParseTime = true
+	// report matched instead of changed rows like postgres and sqlite do
+	mysqlCfg.ClientFoundRows = true
+	return mysqlCfg, nil
+}
+
+func parseMySQLDataSourceName(dsn string) (*mysql.Config, error) {
+	if !strings.HasPrefix(dsn, "mysql://") {
+		return mysql.ParseDSN(dsn)
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "3306")
+	}
+	goDSN := "tcp(" + host + ")/" + strings.TrimPrefix(u.Path, "/")
+	if u.RawQuery != "" {
+		goDSN += "?" + u.RawQuery
+	}
+
+	mysqlCfg, err := mysql.ParseDSN(goDSN)
+	if err != nil {
+		return nil, err
+	}
+	mysqlCfg.User = u.User.Username()
+	mysqlCfg.Passwd, _ = u.User.Password()
+	return mysqlCfg, nil
+}
+
+// mysqlTLSConfig returns the name of the tls config for the ssl mode, which follows the postgres ssl modes.
+// A custom tls config is registered when certificates are configured.
+func (c Config) mysqlTLSConfig() (string, error) {
+	if c.SSLMode == "" || c.SSLMode == "disable" {
+		return "false", nil
+	}
+	if c.SSLRootCert == "" && c.SSLCert == "" && c.SSLKey == "" {
+		switch c.SSLMode {
+		case "allow", "prefer":
+			return "preferred", nil
+		case "require":
+			return "skip-verify", nil
+		default:
+			return "true", nil
+		}
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: c.Host,
+	}
+	if c.SSLRootCert != "" {
+		rootCert, err := os.ReadFile(c.SSLRootCert)
+		if err != nil {
+			return "", fmt.Errorf("failed to read ssl root cert: %w", err)
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(rootCert) {
+			return "", errors.New("failed to parse ssl root cert")
+		}
+	}
+	if c.SSLCert != "" || c.SSLKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.SSLCert, c.SSLKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to load ssl client cert: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch c.SSLMode {
+	case "verify-full":
+	case "verify-ca":
+		// verify the chain without the hostname
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = verifyCertificateChain(tlsCfg.RootCAs)
+	default:
+		// like postgres, the server is only verified if a root cert is set
+		tlsCfg.InsecureSkipVerify = true
+		if c.SSLRootCert != "" {
+			tlsCfg.VerifyPeerCertificate = verifyCertificateChain(tlsCfg.RootCAs)
+		}
+	}
+
+	if err := mysql.RegisterTLSConfig("gobin", tlsCfg); err != nil {
+		return "", fmt.Errorf("failed to register tls config: %w", err)
+	}
+	return "gobin", nil
+}
+
+func verifyCertificateChain(roots *x509.CertPool) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no server certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, rawCert := range rawCerts {
+			cert, err := x509.ParseCertificate(rawCert)
+			if err != nil {
+				return fmt.Errorf("failed to parse server certificate: %w", err)
+			}
+			certs[i] = cert
+		}
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		})
+		return err
+	}
+}
+
+var (
+	sqliteJournalModes = []string{"delete", "truncate", "persist", "memory", "wal", "off"}
+	sqliteSynchronous  = []string{"off", "normal", "full", "extra"}
+)
+
+// SQLiteDataSourceName returns the path with the configured pragmas, which are applied to every connection.
+func (c Config) SQLiteDataSourceName() (string, error) {
+	var pragmas []string
+	if c.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", time.Duration(c.BusyTimeout).Milliseconds()))
+	}
+	if c.JournalMode != "" {
+		if !slices.Contains(sqliteJournalModes, strings.ToLower(c.JournalMode)) {
+			return "", fmt.Errorf("invalid sqlite journal_mode: %s, must be one of: %s", c.JournalMode, strings.Join(sqliteJournalModes, ", "))
+		}
+		pragmas = append(pragmas, fmt.Sprintf("journal_mode(%s)", strings.ToLower(c.JournalMode)))
+	}
+	if c.Synchronous != "" {
+		if !slices.Contains(sqliteSynchronous, strings.ToLower(c.Synchronous)) {
+			return "", fmt.Errorf("invalid sqlite synchronous: %s, must be one of: %s", c.Synchronous, strings.Join(sqliteSynchronous, ", "))
+		}
+		pragmas = append(pragmas, fmt.Sprintf("synchronous(%s)", strings.ToLower(c.Synchronous)))
+	}
+	if c.Encryption != nil {
+		// overwrite deleted content instead of leaving it in free pages
+		pragmas = append(pragmas, "secure_delete(1)")
+	}
+
+	if len(pragmas) == 0 {
+		return c.Path, nil
+	}
+	query := make(url.Values)
+	query["_pragma"] = pragmas
+	return c.Path + "?" + query.Encode(), nil
 }
 
-var _ gomigrate.Queryer = (*DB)(nil)
+var (
+	_ gomigrate.Queryer = (*DB)(nil)
+	_ Store             = (*DB)(nil)
+)
 
 func New(ctx context.Context, cfg Config) (*DB, error) {
 	var (
@@ -94,36 +470,144 @@ func New(ctx context.Context, cfg Config) (*DB, error) {
 		dataSourceName string
 		dbSystem       attribute.KeyValue
 	)
+	if cfg.ReadReplicas != nil && cfg.Type != TypePostgres {
+		return nil, errors.New("read replicas are only supported for postgres")
+	}
+
 	switch cfg.Type {
 	case "postgres":
 		driverName = "pgx"
 		dbSystem = semconv.DBSystemPostgreSQL
-		pgCfg, err := pgx.ParseConfig(cfg.PostgresDataSourceName())
-		if err != nil {
+		var err error
+		if dataSourceName, err = cfg.registerPostgresConnConfig(cfg.PostgresDataSourceName()); err != nil {
 			return nil, err
 		}
-
-		if cfg.Debug {
-			pgCfg.Tracer = &tracelog.TraceLog{
-				Logger: tracelog.LoggerFunc(func(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
-					args := make([]any, 0, len(data))
-					for k, v := range data {
-						args = append(args, slog.Any(k, v))
-					}
-					slog.DebugContext(ctx, msg, slog.Group("data", args...))
-				}),
-				LogLevel: tracelog.LogLevelDebug,
-			}
-		}
-		dataSourceName = stdlib.RegisterConnConfig(pgCfg)
 	case "sqlite":
 		driverName = "sqlite"
 		dbSystem = semconv.DBSystemSqlite
-		dataSourceName = cfg.Path
+		var err error
+		if dataSourceName, err = cfg.SQLiteDataSourceName(); err != nil {
+			return nil, err
+		}
+	case "mysql":
+		driverName = "mysql"
+		dbSystem = semconv.DBSystemMySQL
+		var err error
+		if dataSourceName, err = cfg.MySQLDataSourceName(); err != nil {
+			return nil, err
+		}
+		if cfg.StatementTimeout > 0 {
+			if dataSourceName, err = withMySQLStatementTimeout(ctx, dataSourceName, time.Duration(cfg.StatementTimeout)); err != nil {
+				return nil, err
+			}
+		}
 	default:
-		return nil, errors.New("invalid database type, must be one of: postgres, sqlite")
+		return nil, errors.New("invalid database type, must be one of: postgres, sqlite, mysql")
+	}
+
+	dbx, err := openDB(ctx, cfg, driverName, dataSourceName, dbSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		replicas   []*sqlx.DB
+		compressor *compressor
+		opened     bool
+	)
+	// close everything opened so far if any of the following steps fails
+	defer func() {
+		if opened {
+			return
+		}
+		if compressor != nil {
+			compressor.close()
+		}
+		for _, replica := range replicas {
+			_ = replica.Close()
+		}
+		_ = dbx.Close()
+	}()
+
+	if cfg.ReadReplicas != nil {
+		for i, dsn := range cfg.ReadReplicas.DSNs {
+			replicaDataSourceName, err := cfg.registerPostgresConnConfig(dsn)
+			if err != nil {
+				return nil, fmt.Errorf("invalid read replica %d: %w", i, err)
+			}
+			replica, err := openDB(ctx, cfg, driverName, replicaDataSourceName, dbSystem)
+			if err != nil {
+				return nil, fmt.Errorf("invalid read replica %d: %w", i, err)
+			}
+			replicas = append(replicas, replica)
+		}
+	}
+
+	compressor, err = newCompressor(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := newBlobStore(ctx, cfg.Blob)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptor, err := newEncryptor(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	documentIDs, err := newIDGenerator(cfg.IDs, sqlSequence(dbx, "documents"))
+	if err != nil {
+		return nil, err
+	}
+	webhookIDs, err := newIDGenerator(IDConfig{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	leaseHolder, err := newLeaseHolder(ctx, webhookIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	jobsCtx, jobsCancel := context.WithCancel(context.Background())
+	d := &DB{
+		DB:          dbx,
+		replicas:    replicas,
+		cfg:         cfg,
+		documentIDs: documentIDs,
+		webhookIDs:  webhookIDs,
+		compressor:  compressor,
+		blobs:       blobs,
+		encryptor:   encryptor,
+		leaseHolder: leaseHolder,
+		events:      newEventBus(),
+		jobsCancel:  jobsCancel,
 	}
 
+	if cfg.Type == TypePostgres {
+		d.jobsWaitGroup.Add(1)
+		go func() {
+			defer d.jobsWaitGroup.Done()
+			d.listen(jobsCtx)
+		}()
+	}
+
+	if cfg.Compression != nil {
+		d.jobsWaitGroup.Add(1)
+		go func() {
+			defer d.jobsWaitGroup.Done()
+			d.recompress(jobsCtx)
+		}()
+	}
+
+	opened = true
+	return d, nil
+}
+
+func openDB(ctx context.Context, cfg Config, driverName string, dataSourceName string, dbSystem attribute.KeyValue) (*sqlx.DB, error) {
 	sqlDB, err := otelsql.Open(driverName, dataSourceName,
 		otelsql.WithAttributes(dbSystem),
 		otelsql.WithSQLCommenter(true),
@@ -138,30 +622,80 @@ func New(ctx context.Context, cfg Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime))
+	sqlDB.SetConnMaxIdleTime(time.Duration(cfg.ConnMaxIdleTime))
+
 	if err = otelsql.RegisterDBStatsMetrics(sqlDB, otelsql.WithAttributes(dbSystem)); err != nil {
+		_ = sqlDB.Close()
 		return nil, fmt.Errorf("failed to register database stats metrics: %w", err)
 	}
 
 	dbx := sqlx.NewDb(sqlDB, driverName)
 	if err = dbx.PingContext(ctx); err != nil {
+		_ = dbx.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
-
-	return &DB{
-		DB:   dbx,
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-	}, nil
+	return dbx, nil
 }
 
 type DB struct {
 	*sqlx.DB
-	rand *rand.Rand
+	replicas      []*sqlx.DB
+	nextReplica   atomic.Uint64
+	cfg           Config
+	documentIDs   IDGenerator
+	webhookIDs    IDGenerator
+	compressor    *compressor
+	blobs         BlobStore
+	encryptor     *encryptor
+	leaseHolder   string
+	leaderMu      sync.Mutex
+	leaderConn    *sql.Conn
+	events        *eventBus
+	jobsCancel    context.CancelFunc
+	jobsWaitGroup sync.WaitGroup
+}
+
+// Close stops all background jobs and closes the database.
+func (d *DB) Close() error {
+	d.jobsCancel()
+	d.jobsWaitGroup.Wait()
+	d.events.close()
+	d.compressor.close()
+	for _, replica := range d.replicas {
+		_ = replica.Close()
+	}
+	return d.DB.Close()
 }
 
-func (d *DB) randomString(length int) string {
-	b := make([]rune, length)
-	for i := range b {
-		b[i] = chars[d.rand.Intn(len(chars))]
+func (d *DB) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := d.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// upsertClause returns the clause which updates the given columns if a row with the same key already exists.
+func (d *DB) upsertClause(key string, columns ...string) string {
+	sets := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if d.cfg.Type == TypeMySQL {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		} else {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+	if d.cfg.Type == TypeMySQL {
+		return "ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
 	}
-	return string(b)
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", key, strings.Join(sets, ", "))
 }
@@ -3,15 +3,19 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 type File struct {
 	DocumentID      string     `db:"document_id"`
 	DocumentVersion int64      `db:"document_version"`
 	Name            string     `db:"name"`
-	Content         string     `db:"content"`
+	ContentHash     string     `db:"content_hash"`
+	Content         string     `db:"-"`
 	Language        string     `db:"language"`
 	ExpiresAt       *time.Time `db:"expires_at"`
 	OrderIndex      int        `db:"order_index"`
@@ -23,60 +27,110 @@ type Document struct {
 	Files   []File
 }
 
+// VersionInfo is the optional commit style message and author label of a document version.
+type VersionInfo struct {
+	Message string `db:"message"`
+	Author  string `db:"author"`
+}
+
+// Version is a document version with its message and author.
+type Version struct {
+	Version int64 `db:"version"`
+	VersionInfo
+}
+
 func (d *DB) GetDocument(ctx context.Context, documentID string) ([]File, error) {
 	var files []File
-	if err := d.SelectContext(ctx, &files, "SELECT name, document_id, document_version, content, language, expires_at from (SELECT *, rank() OVER (PARTITION BY document_id ORDER BY document_version DESC) AS rank FROM files) AS f WHERE document_id = $1 AND rank = 1 ORDER BY order_index;", documentID); err != nil {
-		return nil, fmt.Errorf("failed to get document: %w", err)
-	}
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		files = nil
+		if err := q.SelectContext(ctx, &files, q.Rebind("SELECT f.name, f.document_id, f.document_version, f.content_hash, f.language, f.expires_at, f.order_index FROM documents d JOIN files f ON f.document_id = d.id AND f.document_version = d.latest_version WHERE d.id = ? ORDER BY f.order_index;"), documentID); err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
 
-	if len(files) == 0 {
-		return nil, sql.ErrNoRows
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err := d.loadContents(ctx, q, files); err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 	return files, nil
 }
 
 func (d *DB) GetDocumentVersion(ctx context.Context, documentID string, documentVersion int64) ([]File, error) {
 	var files []File
-	if err := d.SelectContext(ctx, &files, "SELECT name, document_id, document_version, content, language, expires_at from files WHERE document_id = $1 AND document_version = $2 ORDER BY order_index;", documentID, documentVersion); err != nil {
-		return nil, fmt.Errorf("failed to get document version: %w", err)
-	}
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		files = nil
+		if err := q.SelectContext(ctx, &files, q.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE document_id = ? AND document_version = ? ORDER BY order_index;"), documentID, documentVersion); err != nil {
+			return fmt.Errorf("failed to get document version: %w", err)
+		}
 
-	if len(files) == 0 {
-		return nil, sql.ErrNoRows
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err := d.loadContents(ctx, q, files); err != nil {
+			return fmt.Errorf("failed to get document version: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 	return files, nil
 }
 
 func (d *DB) GetVersionCount(ctx context.Context, documentID string) (int, error) {
 	var count int
-	err := d.GetContext(ctx, &count, "SELECT COUNT(DISTINCT document_version) FROM files WHERE document_id = $1;", documentID)
+	err := d.read(ctx, func(q *sqlx.DB) error {
+		return q.GetContext(ctx, &count, q.Rebind("SELECT COUNT(*) FROM versions WHERE document_id = ?;"), documentID)
+	})
 	return count, err
 }
 
 func (d *DB) GetDocumentVersions(ctx context.Context, documentID string) ([]int64, error) {
 	var versions []int64
-	if err := d.SelectContext(ctx, &versions, "SELECT DISTINCT document_version FROM files WHERE document_id = $1 ORDER BY document_version DESC;", documentID); err != nil {
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		versions = nil
+		return q.SelectContext(ctx, &versions, q.Rebind("SELECT version FROM versions WHERE document_id = ? ORDER BY version DESC;"), documentID)
+	}); err != nil {
 		return nil, fmt.Errorf("failed to get document versions: %w", err)
 	}
 	return versions, nil
-
 }
 
-func (d *DB) GetDocumentVersionsWithFiles(ctx context.Context, documentID string, withContent bool) (map[int64][]File, error) {
-	var query string
-	if withContent {
-		query = "SELECT name, document_id, document_version, content, language, expires_at WHERE document_id = $1 ORDER BY document_version DESC;"
-	} else {
-		query = "SELECT name, document_id, document_version, language, expires_at WHERE document_id = $1 ORDER BY document_version DESC;"
+// GetDocumentVersionsWithInfo returns the versions of the document from newest to oldest with their message and author.
+func (d *DB) GetDocumentVersionsWithInfo(ctx context.Context, documentID string) ([]Version, error) {
+	var versions []Version
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		versions = nil
+		return q.SelectContext(ctx, &versions, q.Rebind("SELECT version, COALESCE(message, '') AS message, COALESCE(author, '') AS author FROM versions WHERE document_id = ? ORDER BY version DESC;"), documentID)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
 	}
+	return versions, nil
+}
 
+func (d *DB) GetDocumentVersionsWithFiles(ctx context.Context, documentID string, withContent bool) (map[int64][]File, error) {
 	var files []File
-	if err := d.SelectContext(ctx, &files, query, documentID); err != nil {
-		return nil, fmt.Errorf("failed to get document: %w", err)
-	}
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		files = nil
+		if err := q.SelectContext(ctx, &files, q.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE document_id = ? ORDER BY document_version DESC, order_index;"), documentID); err != nil {
+			return fmt.Errorf("failed to get document: %w", err)
+		}
 
-	if len(files) == 0 {
-		return nil, sql.ErrNoRows
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if withContent {
+			if err := d.loadContents(ctx, q, files); err != nil {
+				return fmt.Errorf("failed to get document: %w", err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	mapFiles := make(map[int64][]File)
@@ -84,106 +138,248 @@ func (d *DB) GetDocumentVersionsWithFiles(ctx context.Context, documentID string
 		mapFiles[file.DocumentVersion] = append(mapFiles[file.DocumentVersion], file)
 	}
 	return mapFiles, nil
-
 }
 
-func (d *DB) CreateDocument(ctx context.Context, files []File) (*string, *int64, error) {
-	documentID := d.randomString(8)
-	version := time.Now().UnixMilli()
-	for i := range files {
-		files[i].DocumentID = documentID
-		files[i].DocumentVersion = version
+// CreateDocument creates a new document with a generated id, or with the slug as id if it is not empty.
+// It returns ErrSlugTaken if the slug is already used by another document or alias.
+func (d *DB) CreateDocument(ctx context.Context, files []File, slug string, info VersionInfo) (*string, *int64, error) {
+	now := time.Now()
+	version := now.UnixMilli()
+
+	insert := func(documentID string) error {
+		for i := range files {
+			files[i].DocumentID = documentID
+			files[i].DocumentVersion = version
+		}
+
+		return d.withTx(ctx, func(tx *sqlx.Tx) error {
+			if err := checkSlugAvailable(ctx, tx, documentID); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO documents (id, latest_version, created_at) VALUES (?, ?, ?);"), documentID, version, now); err != nil {
+				if isUniqueViolation(err) {
+					return ErrSlugTaken
+				}
+				return err
+			}
+			if err := insertVersion(ctx, tx, documentID, version, info); err != nil {
+				return err
+			}
+			if err := d.putContents(ctx, tx, files); err != nil {
+				return err
+			}
+			_, err := tx.NamedExecContext(ctx, "INSERT INTO files (name, document_id, document_version, content_hash, language, expires_at, order_index) VALUES (:name, :document_id, :document_version, :content_hash, :language, :expires_at, :order_index);", files)
+			return err
+		})
+	}
+
+	if slug != "" {
+		if err := insert(slug); err != nil {
+			return nil, nil, fmt.Errorf("failed to create document: %w", err)
+		}
+		d.publish(ctx, Event{Type: EventTypeCreate, DocumentID: slug, Version: version})
+		return &slug, &version, nil
 	}
 
-	if _, err := d.NamedExecContext(ctx, "INSERT INTO files (name, document_id, document_version, content, language, expires_at, order_index) VALUES (:name, :document_id, :document_version, :content, :language, :expires_at, :order_index);", files); err != nil {
+	documentID, err := withNewID(ctx, d.documentIDs, d.cfg.IDs.MaxRetries, func(documentID string) error {
+		if err := insert(documentID); err != nil {
+			if errors.Is(err, ErrSlugTaken) {
+				return errIDTaken
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create document: %w", err)
 	}
+	d.publish(ctx, Event{Type: EventTypeCreate, DocumentID: documentID, Version: version})
 	return &documentID, &version, nil
 }
 
-func (d *DB) UpdateDocument(ctx context.Context, documentID string, files []File) (*int64, error) {
-	version := time.Now().UnixMilli()
-	for i := range files {
-		files[i].DocumentID = documentID
-		files[i].DocumentVersion = version
-	}
-	if _, err := d.NamedExecContext(ctx, "INSERT INTO files (name, document_id, document_version, content, language, expires_at) VALUES (:name, :document_id, :document_version, :content, :language, :expires_at);", files); err != nil {
+// UpdateDocument adds a new version to the document. The version is the current time in milliseconds,
+// or the latest version plus one if that is not newer, so updates in the same millisecond don't collide.
+func (d *DB) UpdateDocument(ctx context.Context, documentID string, files []File, info VersionInfo) (*int64, error) {
+	var (
+		version int64
+		blobs   deltaBlobs
+	)
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		now := time.Now().UnixMilli()
+		// bumping the latest version first locks the document row until the transaction ends
+		res, err := tx.ExecContext(ctx, tx.Rebind("UPDATE documents SET latest_version = CASE WHEN latest_version >= ? THEN latest_version + 1 ELSE ? END WHERE id = ?;"), now, now, documentID)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			return sql.ErrNoRows
+		}
+		if err = tx.GetContext(ctx, &version, tx.Rebind("SELECT latest_version FROM documents WHERE id = ?;"), documentID); err != nil {
+			return err
+		}
+		for i := range files {
+			files[i].DocumentID = documentID
+			files[i].DocumentVersion = version
+		}
+
+		var previousFiles []File
+		if err = tx.SelectContext(ctx, &previousFiles, tx.Rebind("SELECT name, content_hash FROM files WHERE document_id = ? AND document_version = (SELECT MAX(version) FROM versions WHERE document_id = ?);"), documentID, documentID); err != nil {
+			return err
+		}
+
+		if err = insertVersion(ctx, tx, documentID, version, info); err != nil {
+			return err
+		}
+		if err = d.putContents(ctx, tx, files); err != nil {
+			return err
+		}
+		if _, err = tx.NamedExecContext(ctx, "INSERT INTO files (name, document_id, document_version, content_hash, language, expires_at, order_index) VALUES (:name, :document_id, :document_version, :content_hash, :language, :expires_at, :order_index);", files); err != nil {
+			return err
+		}
+		return d.encodeDeltas(ctx, tx, previousFiles, files, &blobs)
+	})
+	d.cleanupDeltaBlobs(ctx, blobs, err)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update document: %w", err)
 	}
+	d.publish(ctx, Event{Type: EventTypeUpdate, DocumentID: documentID, Version: version})
 	return &version, nil
 }
 
+// insertVersion inserts a version, an empty message or author is stored as NULL.
+func insertVersion(ctx context.Context, tx *sqlx.Tx, documentID string, version int64, info VersionInfo) error {
+	_, err := tx.ExecContext(ctx, tx.Rebind("INSERT INTO versions (document_id, version, message, author) VALUES (?, ?, ?, ?);"),
+		documentID, version, sql.NullString{String: info.Message, Valid: info.Message != ""}, sql.NullString{String: info.Author, Valid: info.Author != ""},
+	)
+	return err
+}
+
 func (d *DB) DeleteDocument(ctx context.Context, documentID string) (*Document, error) {
-	var files []File
-	if err := d.SelectContext(ctx, &files, "DELETE FROM files WHERE document_id = $1 RETURNING *", documentID); err != nil {
-		return nil, fmt.Errorf("failed to delete document: %w", err)
-	}
+	var document *Document
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var files []File
+		if err := tx.SelectContext(ctx, &files, tx.Rebind("SELECT f.name, f.document_id, f.document_version, f.content_hash, f.language, f.expires_at, f.order_index FROM documents d JOIN files f ON f.document_id = d.id AND f.document_version = d.latest_version WHERE d.id = ? ORDER BY f.order_index;"), documentID); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err := d.loadContents(ctx, tx, files); err != nil {
+			return err
+		}
 
-	if len(files) == 0 {
-		return nil, sql.ErrNoRows
-	}
+		if err := deleteDocument(ctx, tx, documentID); err != nil {
+			return err
+		}
 
-	var lastDeletedFiles []File
-	for i := len(files) - 1; i >= 0; i-- {
-		if files[i].DocumentVersion != files[len(files)-1].DocumentVersion {
-			break
+		document = &Document{
+			ID:      documentID,
+			Version: files[0].DocumentVersion,
+			Files:   files,
 		}
-		lastDeletedFiles = append(lastDeletedFiles, files[i])
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete document: %w", err)
 	}
+	d.publish(ctx, Event{Type: EventTypeDelete, DocumentID: documentID, Version: document.Version})
 
-	return &Document{
-		ID:      documentID,
-		Version: files[len(files)-1].DocumentVersion,
-		Files:   lastDeletedFiles,
-	}, nil
+	return document, nil
 }
 
 func (d *DB) DeleteDocumentVersion(ctx context.Context, documentID string, documentVersion int64) (*Document, error) {
-	var files []File
-	if err := d.SelectContext(ctx, &files, "DELETE FROM files WHERE document_id = $1 AND document_version = $2 RETURNING *;", documentID, documentVersion); err != nil {
-		return nil, fmt.Errorf("failed to delete document version: %w", err)
-	}
-
-	if len(files) == 0 {
-		return nil, sql.ErrNoRows
-	}
+	var (
+		files         []File
+		latestVersion sql.NullInt64
+	)
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.SelectContext(ctx, &files, tx.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE document_id = ? AND document_version = ? ORDER BY order_index;"), documentID, documentVersion); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err := d.loadContents(ctx, tx, files); err != nil {
+			return err
+		}
 
-	var lastDeletedFiles []File
-	for i := len(files) - 1; i >= 0; i-- {
-		if files[i].DocumentVersion != files[len(files)-1].DocumentVersion {
-			break
+		if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM files WHERE document_id = ? AND document_version = ?;"), documentID, documentVersion); err != nil {
+			return err
 		}
-		lastDeletedFiles = append(lastDeletedFiles, files[i])
+		if err := pruneVersions(ctx, tx, documentID); err != nil {
+			return err
+		}
+		return tx.GetContext(ctx, &latestVersion, tx.Rebind("SELECT MAX(version) FROM versions WHERE document_id = ?;"), documentID)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to delete document version: %w", err)
+	}
+	if latestVersion.Valid {
+		d.publish(ctx, Event{Type: EventTypeUpdate, DocumentID: documentID, Version: latestVersion.Int64})
+	} else {
+		d.publish(ctx, Event{Type: EventTypeDelete, DocumentID: documentID, Version: documentVersion})
 	}
 
 	return &Document{
 		ID:      documentID,
 		Version: documentVersion,
-		Files:   lastDeletedFiles,
+		Files:   files,
 	}, nil
 }
 
 func (d *DB) DeleteDocumentVersions(ctx context.Context, documentID string) error {
-	if _, err := d.ExecContext(ctx, "DELETE FROM files WHERE document_id = $1;", documentID); err != nil {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		return deleteDocument(ctx, tx, documentID)
+	}); err != nil {
 		return fmt.Errorf("failed to delete document versions: %w", err)
 	}
+	d.publish(ctx, Event{Type: EventTypeDelete, DocumentID: documentID})
 	return nil
 }
 
 func (d *DB) DeleteExpiredDocuments(ctx context.Context, expireAfter time.Duration) ([]Document, error) {
 	now := time.Now()
-	query := "DELETE FROM files WHERE expires_at < $1"
-	args := []interface{}{now}
+	where := "expires_at < ?"
+	args := []any{now}
 	if expireAfter > 0 {
-		query += " OR document_version < $2"
-		args = append(args, now.Add(expireAfter).UnixMilli())
+		where += " OR document_version < ?"
+		args = append(args, now.Add(-expireAfter).UnixMilli())
 	}
-	query += " RETURNING *;"
+
 	var files []File
-	if err := d.SelectContext(ctx, &files, query, args...); err != nil {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.SelectContext(ctx, &files, tx.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE "+where+" ORDER BY document_id, document_version, order_index;"), args...); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		if err := d.loadContents(ctx, tx, files); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM files WHERE "+where+";"), args...); err != nil {
+			return err
+		}
+
+		for i, file := range files {
+			if i > 0 && files[i-1].DocumentID == file.DocumentID {
+				continue
+			}
+			if err := pruneVersions(ctx, tx, file.DocumentID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
 		return nil, fmt.Errorf("failed to delete expired documents: %w", err)
 	}
 
+	// contents are shared between documents, so they are only removed once no file references them anymore
+	if err := d.deleteOrphanedContents(ctx); err != nil {
+		return nil, err
+	}
+
 	documents := make(map[string]Document)
 	for _, file := range files {
 		document, ok := documents[file.DocumentID]
@@ -202,8 +398,55 @@ func (d *DB) DeleteExpiredDocuments(ctx context.Context, expireAfter time.Durati
 	}
 
 	documentsSlice := make([]Document, 0, len(documents))
+	events := make([]Event, 0, len(documents))
 	for _, document := range documents {
 		documentsSlice = append(documentsSlice, document)
+
+		event := Event{Type: EventTypeUpdate, DocumentID: document.ID, Version: document.Version}
+		if count, err := d.GetVersionCount(ctx, document.ID); err == nil && count == 0 {
+			event.Type = EventTypeDelete
+		}
+		events = append(events, event)
 	}
+	d.publish(ctx, events...)
 	return documentsSlice, nil
 }
+
+// deleteDocument removes all files, versions and the document row itself.
+func deleteDocument(ctx context.Context, tx *sqlx.Tx, documentID string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM files WHERE document_id = ?;"), documentID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM versions WHERE document_id = ?;"), documentID); err != nil {
+		return err
+	}
+	return deleteDocumentRow(ctx, tx, documentID)
+}
+
+// deleteDocumentRow removes the document row and its aliases.
+func deleteDocumentRow(ctx context.Context, tx *sqlx.Tx, documentID string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM document_aliases WHERE document_id = ?;"), documentID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM documents WHERE id = ?;"), documentID)
+	return err
+}
+
+// pruneVersions removes versions without any files left and moves the latest version pointer of the document.
+// If no version is left, the document row is removed as well.
+func pruneVersions(ctx context.Context, tx *sqlx.Tx, documentID string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM versions WHERE document_id = ? AND version NOT IN (SELECT document_version FROM files WHERE document_id = ?);"), documentID, documentID); err != nil {
+		return err
+	}
+
+	var latestVersion sql.NullInt64
+	if err := tx.GetContext(ctx, &latestVersion, tx.Rebind("SELECT MAX(version) FROM versions WHERE document_id = ?;"), documentID); err != nil {
+		return err
+	}
+	if !latestVersion.Valid {
+		return deleteDocumentRow(ctx, tx, documentID)
+	}
+
+	_, err := tx.ExecContext(ctx, tx.Rebind("UPDATE documents SET latest_version = ? WHERE id = ?;"), latestVersion.Int64, documentID)
+	return err
+}
@@ -0,0 +1,312 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var ErrEncryptionNotConfigured = errors.New("content is encrypted but no encryption is configured")
+
+type EncryptionConfig struct {
+	KeyID           string            `toml:"key_id"`
+	Keys            map[string]string `toml:"keys"`
+	KeyFile         string            `toml:"key_file"`
+	RotateBatchSize int               `toml:"rotate_batch_size"`
+}
+
+func (c EncryptionConfig) String() string {
+	keyIDs := make([]string, 0, len(c.Keys))
+	for keyID := range c.Keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	return fmt.Sprintf("\n   KeyID: %s\n   Keys: %s\n   KeyFile: %s\n   RotateBatchSize: %d",
+		c.KeyID,
+		strings.Join(keyIDs, ", "),
+		c.KeyFile,
+		c.RotateBatchSize,
+	)
+}
+
+// loadKeys returns the base64 encoded keys from the config and the key file.
+// The key file contains one key per line in the format "<key id>=<base64 key>", lines starting with # are ignored.
+func (c EncryptionConfig) loadKeys() (map[string]string, error) {
+	keys := make(map[string]string, len(c.Keys))
+	for keyID, key := range c.Keys {
+		keys[keyID] = key
+	}
+	if c.KeyFile == "" {
+		return keys, nil
+	}
+
+	data, err := os.ReadFile(c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyID, key, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in key file: %q", line)
+		}
+		keys[strings.TrimSpace(keyID)] = strings.TrimSpace(key)
+	}
+	return keys, scanner.Err()
+}
+
+func newEncryptor(cfg *EncryptionConfig) (*encryptor, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	keys, err := cfg.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &encryptor{
+		keyID: cfg.KeyID,
+		keys:  make(map[string]cipher.AEAD, len(keys)),
+	}
+	for keyID, key := range keys {
+		rawKey, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode encryption key %s: %w", keyID, err)
+		}
+		if e.keys[keyID], err = newAEAD(rawKey); err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %w", keyID, err)
+		}
+	}
+	if _, ok := e.keys[e.keyID]; !ok {
+		return nil, fmt.Errorf("encryption key %s not found", e.keyID)
+	}
+	return e, nil
+}
+
+// sealOverhead is how many bytes sealAEAD adds to the data, the nonce and the tag of AES-GCM.
+const sealOverhead = 12 + 16
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptor implements envelope encryption of contents.
+// Each content is encrypted with its own random data key using AES-GCM.
+// The data key is encrypted with the configured key and stored next to the content together with the key id,
+// so keys can be rotated by only re-encrypting the data keys.
+// The content hash is used as additional data, so encrypted contents can't be swapped between rows.
+type encryptor struct {
+	keyID string
+	keys  map[string]cipher.AEAD
+}
+
+// seal encrypts data with a new data key and returns the encrypted data, the key id and the encrypted data key.
+func (e *encryptor) seal(hash string, data []byte) ([]byte, *string, []byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	sealed, err := sealAEAD(aead, hash, data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sealedDataKey, err := sealAEAD(e.keys[e.keyID], hash, dataKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	keyID := e.keyID
+	return sealed, &keyID, sealedDataKey, nil
+}
+
+// open decrypts data which was encrypted with seal.
+func (e *encryptor) open(hash string, data []byte, keyID string, sealedDataKey []byte) ([]byte, error) {
+	dataKey, err := e.openDataKey(hash, keyID, sealedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return openAEAD(aead, hash, data)
+}
+
+// rewrap re-encrypts a data key with the current key.
+func (e *encryptor) rewrap(hash string, keyID string, sealedDataKey []byte) ([]byte, error) {
+	dataKey, err := e.openDataKey(hash, keyID, sealedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	return sealAEAD(e.keys[e.keyID], hash, dataKey)
+}
+
+func (e *encryptor) openDataKey(hash string, keyID string, sealedDataKey []byte) ([]byte, error) {
+	aead, ok := e.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("encryption key %s not found", keyID)
+	}
+	return openAEAD(aead, hash, sealedDataKey)
+}
+
+func sealAEAD(aead cipher.AEAD, hash string, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, []byte(hash)), nil
+}
+
+func openAEAD(aead cipher.AEAD, hash string, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(hash))
+}
+
+// encryptContent encrypts data if encryption is configured.
+func (d *DB) encryptContent(hash string, data []byte) ([]byte, *string, []byte, error) {
+	if d.encryptor == nil {
+		return data, nil, nil, nil
+	}
+	return d.encryptor.seal(hash, data)
+}
+
+// decryptContent decrypts the stored data of a content if it is encrypted.
+func (d *DB) decryptContent(content Content) ([]byte, error) {
+	if content.KeyID == nil {
+		return content.Content, nil
+	}
+	if d.encryptor == nil {
+		return nil, ErrEncryptionNotConfigured
+	}
+	return d.encryptor.open(content.Hash, content.Content, *content.KeyID, content.DataKey)
+}
+
+// RotateKeys encrypts all contents which are not encrypted yet and re-encrypts the data keys of contents
+// which are encrypted with another key than the current one. Contents are processed in batches, so it is safe
+// to run while the server is handling requests. It returns the number of updated contents.
+func (d *DB) RotateKeys(ctx context.Context) (int, error) {
+	if d.encryptor == nil {
+		return 0, errors.New("encryption is not configured")
+	}
+
+	batchSize := d.cfg.Encryption.RotateBatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	var (
+		total    int
+		lastHash string
+	)
+	for {
+		var contents []Content
+		if err := d.SelectContext(ctx, &contents, d.Rebind("SELECT hash, content, key_id, data_key, blob_key FROM contents WHERE hash > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY hash LIMIT ?;"), lastHash, d.encryptor.keyID, batchSize); err != nil {
+			return total, fmt.Errorf("failed to get contents to rotate: %w", err)
+		}
+		if len(contents) == 0 {
+			break
+		}
+		lastHash = contents[len(contents)-1].Hash
+
+		for _, content := range contents {
+			updated, err := d.rotateContent(ctx, content)
+			if err != nil {
+				return total, fmt.Errorf("failed to rotate content %s: %w", content.Hash, err)
+			}
+			if updated {
+				total++
+			}
+		}
+		slog.InfoContext(ctx, "rotated content keys", slog.Int("count", total))
+	}
+
+	// sqlite keeps the old content in free pages until the database is vacuumed
+	if d.cfg.Type == TypeSQLite {
+		if _, err := d.ExecContext(ctx, "VACUUM;"); err != nil {
+			return total, fmt.Errorf("failed to vacuum database: %w", err)
+		}
+	}
+	return total, nil
+}
+
+// rotateContent re-encrypts a single content. All updates only apply if the content has not been changed in the meantime.
+func (d *DB) rotateContent(ctx context.Context, content Content) (bool, error) {
+	if content.KeyID != nil {
+		dataKey, err := d.encryptor.rewrap(content.Hash, *content.KeyID, content.DataKey)
+		if err != nil {
+			return false, err
+		}
+		res, err := d.ExecContext(ctx, d.Rebind("UPDATE contents SET key_id = ?, data_key = ? WHERE hash = ? AND key_id = ? AND data_key = ?;"), d.encryptor.keyID, dataKey, content.Hash, *content.KeyID, content.DataKey)
+		if err != nil {
+			return false, err
+		}
+		rows, err := res.RowsAffected()
+		return rows > 0, err
+	}
+
+	data := content.Content
+	if content.BlobKey != nil {
+		var err error
+		if data, err = d.loadBlob(ctx, *content.BlobKey); err != nil {
+			return false, err
+		}
+	}
+
+	data, keyID, dataKey, err := d.encryptor.seal(content.Hash, data)
+	if err != nil {
+		return false, err
+	}
+
+	if content.BlobKey == nil {
+		res, err := d.ExecContext(ctx, d.Rebind("UPDATE contents SET content = ?, key_id = ?, data_key = ?, size = ? WHERE hash = ? AND key_id IS NULL AND blob_key IS NULL AND content = ?;"), data, keyID, dataKey, len(data), content.Hash, content.Content)
+		if err != nil {
+			return false, err
+		}
+		rows, err := res.RowsAffected()
+		return rows > 0, err
+	}
+
+	blobKey := blobKey(content.Hash, data)
+	if err = d.blobs.Put(ctx, blobKey, data); err != nil {
+		return false, fmt.Errorf("failed to put blob %s: %w", blobKey, err)
+	}
+	res, err := d.ExecContext(ctx, d.Rebind("UPDATE contents SET blob_key = ?, key_id = ?, data_key = ?, size = ? WHERE hash = ? AND key_id IS NULL AND blob_key = ?;"), blobKey, keyID, dataKey, len(data), content.Hash, *content.BlobKey)
+	if err != nil {
+		return false, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		d.deleteBlobs(ctx, []string{blobKey})
+		return false, nil
+	}
+	d.deleteBlobs(ctx, []string{*content.BlobKey})
+	return true, nil
+}
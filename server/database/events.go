@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/topi314/tint"
+)
+
+const (
+	// eventsChannel is the postgres notification channel documents events are published on.
+	eventsChannel = "gobin_events"
+	// eventsReconnectDelay is how long to wait before listening again after the connection was lost.
+	eventsReconnectDelay = 5 * time.Second
+	// eventsQueueSize is how many events can be queued before publishing blocks.
+	eventsQueueSize = 1024
+)
+
+type EventType string
+
+const (
+	EventTypeCreate EventType = "create"
+	EventTypeUpdate EventType = "update"
+	EventTypeDelete EventType = "delete"
+)
+
+// Event is published whenever a document is created, updated or deleted.
+// With postgres, events of all instances sharing the database are received, otherwise only the ones of this instance.
+type Event struct {
+	Type       EventType `json:"type"`
+	DocumentID string    `json:"document_id"`
+	Version    int64     `json:"version"`
+	// Evicted is the removed document if the memory store evicted it to stay below its max size.
+	// No request deleted it, so the delete webhooks have to be sent by the subscriber.
+	Evicted *Document `json:"-"`
+}
+
+// eventBus dispatches events to all subscribers of this instance.
+// Subscribers are called one after another in the order the events were dispatched.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[int]func(event Event)
+	nextID      int
+	closeMu     sync.RWMutex
+	closed      bool
+	queue       chan Event
+	done        chan struct{}
+}
+
+func newEventBus() *eventBus {
+	b := &eventBus{
+		subscribers: make(map[int]func(event Event)),
+		queue:       make(chan Event, eventsQueueSize),
+		done:        make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Subscribe calls fn for every event until the returned function is called.
+// fn should not block, as it delays all following events.
+func (b *eventBus) Subscribe(fn func(event Event)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+func (b *eventBus) run() {
+	defer close(b.done)
+	for event := range b.queue {
+		// copy the subscribers, so they can subscribe or unsubscribe while being called
+		b.mu.RLock()
+		subscribers := slices.Collect(maps.Values(b.subscribers))
+		b.mu.RUnlock()
+
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	}
+}
+
+// dispatch queues the event, events dispatched after the bus was closed are dropped.
+func (b *eventBus) dispatch(event Event) {
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+	if b.closed {
+		return
+	}
+	b.queue <- event
+}
+
+// close stops the bus after all queued events have been dispatched.
+func (b *eventBus) close() {
+	b.closeMu.Lock()
+	b.closed = true
+	close(b.queue)
+	b.closeMu.Unlock()
+	<-b.done
+}
+
+func (d *DB) Subscribe(fn func(event Event)) func() {
+	return d.events.Subscribe(fn)
+}
+
+// publish sends the events to all instances. With postgres they are dispatched once received by the listener,
+// otherwise they are dispatched directly. Failing to publish an event does not fail the change it belongs to.
+func (d *DB) publish(ctx context.Context, events ...Event) {
+	if d.cfg.Type != TypePostgres {
+		for _, event := range events {
+			d.events.dispatch(event)
+		}
+		return
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode event", tint.Err(err))
+			continue
+		}
+		if _, err = d.ExecContext(context.WithoutCancel(ctx), "SELECT pg_notify($1, $2);", eventsChannel, string(payload)); err != nil {
+			slog.ErrorContext(ctx, "failed to publish event", slog.String("type", string(event.Type)), slog.String("document_id", event.DocumentID), tint.Err(err))
+		}
+	}
+}
+
+// listen receives the events of all instances from postgres until the context is canceled.
+// Events published while the connection is lost are not received.
+func (d *DB) listen(ctx context.Context) {
+	for {
+		err := d.listenEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.ErrorContext(ctx, "event listener stopped, reconnecting", slog.Duration("delay", eventsReconnectDelay), tint.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(eventsReconnectDelay):
+		}
+	}
+}
+
+func (d *DB) listenEvents(ctx context.Context) error {
+	pgCfg, err := d.cfg.PostgresConnConfig()
+	if err != nil {
+		return err
+	}
+	conn, err := pgx.ConnectConfig(ctx, pgCfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+eventsChannel+";"); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	slog.DebugContext(ctx, "listening for events", slog.String("channel", eventsChannel))
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+
+		var event Event
+		if err = json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			slog.ErrorContext(ctx, "failed to decode event", slog.String("payload", notification.Payload), tint.Err(err))
+			continue
+		}
+		d.events.dispatch(event)
+	}
+}
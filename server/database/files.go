@@ -3,12 +3,24 @@ package database
 import (
 	"context"
 	"fmt"
+
+	"github.com/jmoiron/sqlx"
 )
 
 func (d *DB) GetDocumentFile(ctx context.Context, documentID string, fileName string) (*File, error) {
 	var file File
-	if err := d.GetContext(ctx, &file, "SELECT name, document_id, document_version, content, language, expires_at from (SELECT *, rank() OVER (PARTITION BY document_id ORDER BY document_version DESC) AS rank FROM files) AS f WHERE document_id = $1 AND name = $2 AND rank = 1;", documentID, fileName); err != nil {
-		return nil, fmt.Errorf("failed to get document file: %w", err)
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		if err := q.GetContext(ctx, &file, q.Rebind("SELECT f.name, f.document_id, f.document_version, f.content_hash, f.language, f.expires_at, f.order_index FROM documents d JOIN files f ON f.document_id = d.id AND f.document_version = d.latest_version WHERE d.id = ? AND f.name = ?;"), documentID, fileName); err != nil {
+			return fmt.Errorf("failed to get document file: %w", err)
+		}
+		files := []File{file}
+		if err := d.loadContents(ctx, q, files); err != nil {
+			return fmt.Errorf("failed to get document file: %w", err)
+		}
+		file = files[0]
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return &file, nil
@@ -16,15 +28,31 @@ func (d *DB) GetDocumentFile(ctx context.Context, documentID string, fileName st
 
 func (d *DB) GetDocumentFileVersion(ctx context.Context, documentID string, documentVersion int64, fileName string) (*File, error) {
 	var file File
-	if err := d.GetContext(ctx, &file, "SELECT name, document_id, document_version, content, language, expires_at from files WHERE document_id = $1 AND document_version = $2 AND name = $3;", documentID, documentVersion, fileName); err != nil {
-		return nil, fmt.Errorf("failed to get document file version: %w", err)
+	if err := d.read(ctx, func(q *sqlx.DB) error {
+		if err := q.GetContext(ctx, &file, q.Rebind("SELECT name, document_id, document_version, content_hash, language, expires_at, order_index FROM files WHERE document_id = ? AND document_version = ? AND name = ?;"), documentID, documentVersion, fileName); err != nil {
+			return fmt.Errorf("failed to get document file version: %w", err)
+		}
+
+		files := []File{file}
+		if err := d.loadContents(ctx, q, files); err != nil {
+			return fmt.Errorf("failed to get document file version: %w", err)
+		}
+		file = files[0]
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
 	return &file, nil
 }
 
 func (d *DB) DeleteDocumentFile(ctx context.Context, documentID string, fileName string) error {
-	if _, err := d.ExecContext(ctx, "DELETE FROM files WHERE document_id = $1 AND name = $2;", documentID, fileName); err != nil {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM files WHERE document_id = ? AND name = ?;"), documentID, fileName); err != nil {
+			return err
+		}
+		return pruneVersions(ctx, tx, documentID)
+	}); err != nil {
 		return fmt.Errorf("failed to delete document file: %w", err)
 	}
 
@@ -32,7 +60,12 @@ func (d *DB) DeleteDocumentFile(ctx context.Context, documentID string, fileName
 }
 
 func (d *DB) DeleteDocumentVersionFile(ctx context.Context, documentID string, documentVersion int64, fileName string) error {
-	if _, err := d.ExecContext(ctx, "DELETE FROM files WHERE document_id = $1 AND document_version = $2 AND name = $3;", documentID, documentVersion, fileName); err != nil {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM files WHERE document_id = ? AND document_version = ? AND name = ?;"), documentID, documentVersion, fileName); err != nil {
+			return err
+		}
+		return pruneVersions(ctx, tx, documentID)
+	}); err != nil {
 		return fmt.Errorf("failed to delete document version file: %w", err)
 	}
 
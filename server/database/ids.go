@@ -0,0 +1,326 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	defaultIDLength     = 8
+	defaultIDAlphabet   = "abcdefghijklmnopqrstuvwxyz0123456789"
+	defaultIDWordCount  = 4
+	defaultIDSeparator  = "-"
+	defaultIDMaxRetries = 5
+
+	base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+var (
+	//go:embed words.txt
+	defaultWordList []byte
+
+	ErrIDConflict = errors.New("failed to generate a unique id")
+
+	// errIDTaken is returned by insert functions passed to withNewID if the id is already in use.
+	errIDTaken = errors.New("id already taken")
+)
+
+type IDMode string
+
+const (
+	IDModeRandom     IDMode = "random"
+	IDModeWords      IDMode = "words"
+	IDModeSequential IDMode = "sequential"
+)
+
+type IDConfig struct {
+	Mode       IDMode `toml:"mode"`
+	Length     int    `toml:"length"`
+	Alphabet   string `toml:"alphabet"`
+	WordList   string `toml:"word_list"`
+	Separator  string `toml:"separator"`
+	MaxRetries int    `toml:"max_retries"`
+
+	// Reserved are ids which are never generated, because they are used as routes or reserved as slugs.
+	Reserved []string `toml:"-"`
+}
+
+func (c IDConfig) String() string {
+	return fmt.Sprintf("\n   Mode: %s\n   Length: %d\n   Alphabet: %s\n   WordList: %s\n   Separator: %s\n   MaxRetries: %d",
+		c.Mode,
+		c.Length,
+		c.Alphabet,
+		c.WordList,
+		c.Separator,
+		c.MaxRetries,
+	)
+}
+
+// IDGenerator generates new ids. Generated ids are not guaranteed to be unique,
+// inserts have to retry with a new id if the id is already taken.
+type IDGenerator interface {
+	GenerateID(ctx context.Context) (string, error)
+}
+
+var (
+	_ IDGenerator = (*reservedIDGenerator)(nil)
+	_ IDGenerator = (*randomIDGenerator)(nil)
+	_ IDGenerator = (*wordsIDGenerator)(nil)
+	_ IDGenerator = (*sequentialIDGenerator)(nil)
+)
+
+// newIDGenerator creates the generator for the configured mode, which skips the reserved ids.
+// next returns the next value of the counter used by the sequential mode.
+func newIDGenerator(cfg IDConfig, next func(ctx context.Context) (int64, error)) (IDGenerator, error) {
+	generator, err := newModeIDGenerator(cfg, next)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Reserved) == 0 {
+		return generator, nil
+	}
+	return &reservedIDGenerator{
+		generator: generator,
+		reserved:  cfg.Reserved,
+	}, nil
+}
+
+func newModeIDGenerator(cfg IDConfig, next func(ctx context.Context) (int64, error)) (IDGenerator, error) {
+	switch cfg.Mode {
+	case "", IDModeRandom:
+		length := cfg.Length
+		if length <= 0 {
+			length = defaultIDLength
+		}
+		alphabet := cfg.Alphabet
+		if alphabet == "" {
+			alphabet = defaultIDAlphabet
+		}
+		return &randomIDGenerator{
+			length:   length,
+			alphabet: []rune(alphabet),
+		}, nil
+	case IDModeWords:
+		wordList := defaultWordList
+		if cfg.WordList != "" {
+			var err error
+			if wordList, err = os.ReadFile(cfg.WordList); err != nil {
+				return nil, fmt.Errorf("failed to read word list: %w", err)
+			}
+		}
+		words, err := parseWordList(wordList)
+		if err != nil {
+			return nil, err
+		}
+
+		count := cfg.Length
+		if count <= 0 {
+			count = defaultIDWordCount
+		}
+		separator := cfg.Separator
+		if separator == "" {
+			separator = defaultIDSeparator
+		}
+		return &wordsIDGenerator{
+			words:     words,
+			count:     count,
+			separator: separator,
+		}, nil
+	case IDModeSequential:
+		return &sequentialIDGenerator{
+			next: next,
+		}, nil
+	default:
+		return nil, errors.New("invalid id mode, must be one of: random, words, sequential")
+	}
+}
+
+func parseWordList(data []byte) ([]string, error) {
+	var words []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse word list: %w", err)
+	}
+	if len(words) < 2 {
+		return nil, errors.New("word list must contain at least 2 words")
+	}
+	return words, nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// reservedIDGenerator generates ids with the wrapped generator until one is not reserved.
+type reservedIDGenerator struct {
+	generator IDGenerator
+	reserved  []string
+}
+
+func (g *reservedIDGenerator) GenerateID(ctx context.Context) (string, error) {
+	// there can only be as many reserved ids in a row as there are reserved ids
+	for range len(g.reserved) + 1 {
+		id, err := g.generator.GenerateID(ctx)
+		if err != nil {
+			return "", err
+		}
+		if !slices.ContainsFunc(g.reserved, func(reserved string) bool {
+			return strings.EqualFold(reserved, id)
+		}) {
+			return id, nil
+		}
+	}
+	return "", errors.New("only reserved ids were generated")
+}
+
+// randomIDGenerator generates ids of random characters from the alphabet.
+type randomIDGenerator struct {
+	length   int
+	alphabet []rune
+}
+
+func (g *randomIDGenerator) GenerateID(_ context.Context) (string, error) {
+	id := make([]rune, g.length)
+	for i := range id {
+		index, err := randomIndex(len(g.alphabet))
+		if err != nil {
+			return "", err
+		}
+		id[i] = g.alphabet[index]
+	}
+	return string(id), nil
+}
+
+// wordsIDGenerator generates ids of random words joined by the separator.
+type wordsIDGenerator struct {
+	words     []string
+	count     int
+	separator string
+}
+
+func (g *wordsIDGenerator) GenerateID(_ context.Context) (string, error) {
+	words := make([]string, g.count)
+	for i := range words {
+		index, err := randomIndex(len(g.words))
+		if err != nil {
+			return "", err
+		}
+		words[i] = g.words[index]
+	}
+	return strings.Join(words, g.separator), nil
+}
+
+// sequentialIDGenerator generates base62 encoded ids from a counter.
+type sequentialIDGenerator struct {
+	next func(ctx context.Context) (int64, error)
+}
+
+func (g *sequentialIDGenerator) GenerateID(ctx context.Context) (string, error) {
+	value, err := g.next(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeBase62(value), nil
+}
+
+// sqlSequence returns the next value of a counter in the id_sequences table.
+// The counter is incremented in its own transaction, so values are never handed out twice.
+func sqlSequence(db *sqlx.DB, name string) func(ctx context.Context) (int64, error) {
+	return func(ctx context.Context) (int64, error) {
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err = tx.ExecContext(ctx, tx.Rebind("UPDATE id_sequences SET value = value + 1 WHERE name = ?;"), name); err != nil {
+			return 0, fmt.Errorf("failed to increment id sequence: %w", err)
+		}
+		var value int64
+		if err = tx.GetContext(ctx, &value, tx.Rebind("SELECT value FROM id_sequences WHERE name = ?;"), name); err != nil {
+			return 0, fmt.Errorf("failed to get id sequence: %w", err)
+		}
+		if err = tx.Commit(); err != nil {
+			return 0, err
+		}
+		return value, nil
+	}
+}
+
+func encodeBase62(value int64) string {
+	if value == 0 {
+		return base62Alphabet[:1]
+	}
+	var id []byte
+	for value > 0 {
+		id = append(id, base62Alphabet[value%62])
+		value /= 62
+	}
+	for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+		id[i], id[j] = id[j], id[i]
+	}
+	return string(id)
+}
+
+// isUniqueViolation reports whether err was caused by inserting a duplicate primary or unique key.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062
+	}
+	return false
+}
+
+// withNewID calls fn with newly generated ids until it doesn't fail with errIDTaken.
+func withNewID(ctx context.Context, generator IDGenerator, maxRetries int, fn func(id string) error) (string, error) {
+	if maxRetries <= 0 {
+		maxRetries = defaultIDMaxRetries
+	}
+
+	for range maxRetries + 1 {
+		id, err := generator.GenerateID(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate id: %w", err)
+		}
+		if err = fn(id); err != nil {
+			if errors.Is(err, errIDTaken) {
+				continue
+			}
+			return "", err
+		}
+		return id, nil
+	}
+	return "", ErrIDConflict
+}
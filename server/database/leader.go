@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/topi314/gobin/v2/internal/timex"
+)
+
+const (
+	// cleanupLockID is the postgres advisory lock held by the cleanup leader.
+	cleanupLockID int64 = 0x676f62696e // "gobin"
+	// cleanupLeaseName is the lease row held by the cleanup leader.
+	cleanupLeaseName = "cleanup"
+)
+
+type LeaderElectionConfig struct {
+	LeaseDuration timex.Duration `toml:"lease_duration"`
+}
+
+func (c LeaderElectionConfig) String() string {
+	return fmt.Sprintf("\n   LeaseDuration: %s", time.Duration(c.LeaseDuration))
+}
+
+// AcquireLeadership returns true if this instance is the cleanup leader and has to be called again before ttl passes to keep the leadership.
+// Postgres uses a session advisory lock, which is released as soon as the connection of the leader is gone.
+// SQLite and MySQL use a lease row, which can be taken over by another instance once it has not been renewed for ttl.
+func (d *DB) AcquireLeadership(ctx context.Context, ttl time.Duration) (bool, error) {
+	if d.cfg.Type == TypePostgres {
+		return d.acquireAdvisoryLock(ctx)
+	}
+	return d.acquireLease(ctx, ttl)
+}
+
+// ReleaseLeadership gives up the cleanup leadership, so another instance can take over without waiting.
+func (d *DB) ReleaseLeadership(ctx context.Context) error {
+	if d.cfg.Type == TypePostgres {
+		d.leaderMu.Lock()
+		defer d.leaderMu.Unlock()
+		if d.leaderConn == nil {
+			return nil
+		}
+		return d.releaseAdvisoryLock(ctx)
+	}
+
+	if _, err := d.ExecContext(ctx, d.Rebind("DELETE FROM leases WHERE name = ? AND holder = ?;"), cleanupLeaseName, d.leaseHolder); err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) acquireAdvisoryLock(ctx context.Context) (bool, error) {
+	d.leaderMu.Lock()
+	defer d.leaderMu.Unlock()
+
+	if d.leaderConn != nil {
+		if err := d.leaderConn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// the lock is gone with a broken connection, otherwise it is released to not keep it on a pooled connection
+		_ = d.releaseAdvisoryLock(context.WithoutCancel(ctx))
+	}
+
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection for advisory lock: %w", err)
+	}
+
+	var locked bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1);", cleanupLockID).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return false, fmt.Errorf("failed to acquire advisory lock: %w", err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return false, nil
+	}
+	d.leaderConn = conn
+	return true, nil
+}
+
+func (d *DB) releaseAdvisoryLock(ctx context.Context) error {
+	conn := d.leaderConn
+	d.leaderConn = nil
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1);", cleanupLockID); err != nil {
+		return fmt.Errorf("failed to release advisory lock: %w", err)
+	}
+	return nil
+}
+
+func (d *DB) acquireLease(ctx context.Context, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := d.ExecContext(ctx, d.Rebind("UPDATE leases SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at < ?);"), d.leaseHolder, now.Add(ttl), cleanupLeaseName, d.leaseHolder, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	} else if rows > 0 {
+		return true, nil
+	}
+
+	if _, err = d.ExecContext(ctx, d.Rebind("INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?);"), cleanupLeaseName, d.leaseHolder, now.Add(ttl)); err != nil {
+		if isUniqueViolation(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return true, nil
+}
+
+// newLeaseHolder returns a name for this instance which is unique across all instances sharing the database.
+func newLeaseHolder(ctx context.Context, ids IDGenerator) (string, error) {
+	id, err := ids.GenerateID(ctx)
+	if err != nil {
+		return "", err
+	}
+	hostname, _ := os.Hostname()
+	return hostname + "-" + id, nil
+}
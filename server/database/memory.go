@@ -0,0 +1,1336 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/topi314/tint"
+)
+
+var ErrMemoryFull = errors.New("document exceeds the max size of the memory database")
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a Store which keeps everything in memory.
+// If a snapshot path is configured, the snapshot is loaded if it exists and written again every snapshot interval and on Close.
+func NewMemoryStore(cfg Config) (*MemoryStore, error) {
+	m := &MemoryStore{
+		cfg:       cfg,
+		documents: make(map[string]*memoryDocument),
+		aliases:   make(map[string]memoryAlias),
+		webhooks:  make(map[string]Webhook),
+		events:    newEventBus(),
+		verified:  make(map[string]time.Time),
+	}
+
+	var err error
+	if m.documentIDs, err = newIDGenerator(cfg.IDs, func(_ context.Context) (int64, error) {
+		return m.sequence.Add(1), nil
+	}); err != nil {
+		return nil, err
+	}
+	if m.webhookIDs, err = newIDGenerator(IDConfig{}, nil); err != nil {
+		return nil, err
+	}
+
+	if cfg.Snapshot != "" {
+		if err = m.loadSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+
+	jobsCtx, jobsCancel := context.WithCancel(context.Background())
+	m.jobsCancel = jobsCancel
+	if cfg.Snapshot != "" && cfg.SnapshotInterval > 0 {
+		m.jobsWaitGroup.Add(1)
+		go func() {
+			defer m.jobsWaitGroup.Done()
+			m.writeSnapshots(jobsCtx, time.Duration(cfg.SnapshotInterval))
+		}()
+	}
+	return m, nil
+}
+
+// MemoryStore is a Store which keeps documents, versions, aliases and webhooks in process memory.
+// When the total size of all file contents exceeds the configured max size, the oldest documents are evicted.
+type MemoryStore struct {
+	cfg         Config
+	mu          sync.RWMutex
+	documents   map[string]*memoryDocument
+	aliases     map[string]memoryAlias
+	webhooks    map[string]Webhook
+	size        int64
+	sequence    atomic.Int64
+	closed      bool
+	documentIDs IDGenerator
+	webhookIDs  IDGenerator
+	events      *eventBus
+	// pending holds the events of the current change, they are dispatched once the lock is released.
+	pending []Event
+	// verified holds when a content hash was last verified by ScrubContents.
+	verified   map[string]time.Time
+	mismatches []ContentMismatch
+
+	jobsCancel    context.CancelFunc
+	jobsWaitGroup sync.WaitGroup
+}
+
+type memoryDocument struct {
+	ID            string           `json:"id"`
+	LatestVersion int64            `json:"latest_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	ViewedAt      *time.Time       `json:"viewed_at"`
+	Retention     *RetentionPolicy `json:"retention"`
+	DeletedAt     *time.Time       `json:"deleted_at"`
+	Versions      map[int64][]File `json:"versions"`
+	// Infos only has entries for versions with a message or author.
+	Infos map[int64]VersionInfo `json:"infos,omitempty"`
+	Size  int64                 `json:"-"`
+}
+
+type memoryAlias struct {
+	DocumentID string    `json:"document_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type memorySnapshot struct {
+	Documents []*memoryDocument      `json:"documents"`
+	Aliases   map[string]memoryAlias `json:"aliases"`
+	Webhooks  []Webhook              `json:"webhooks"`
+	Sequence  int64                  `json:"sequence"`
+}
+
+func (m *MemoryStore) loadSnapshot() error {
+	data, err := os.ReadFile(m.cfg.Snapshot)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read memory snapshot: %w", err)
+	}
+
+	var snapshot memorySnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode memory snapshot: %w", err)
+	}
+
+	for _, document := range snapshot.Documents {
+		// snapshots written before content hashes were kept in memory don't contain them
+		for _, files := range document.Versions {
+			for i := range files {
+				if files[i].ContentHash == "" {
+					files[i].ContentHash = hashContent(files[i].Content)
+				}
+			}
+		}
+		document.Size = documentSize(document)
+		m.documents[document.ID] = document
+		m.size += document.Size
+	}
+	if snapshot.Aliases != nil {
+		m.aliases = snapshot.Aliases
+	}
+	for _, webhook := range snapshot.Webhooks {
+		m.webhooks[webhook.ID] = webhook
+	}
+	// documents over the max size are evicted by the first cleanup, when the server is subscribed to their delete events
+	m.sequence.Store(snapshot.Sequence)
+
+	slog.Info("Loaded memory snapshot", slog.String("path", m.cfg.Snapshot), slog.Int("documents", len(m.documents)))
+	return nil
+}
+
+// writeSnapshots writes the snapshot every interval until the context is canceled,
+// so a crash only loses the changes since the last snapshot.
+func (m *MemoryStore) writeSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.writeSnapshot(); err != nil {
+				slog.ErrorContext(ctx, "failed to write memory snapshot", tint.Err(err))
+				continue
+			}
+			slog.DebugContext(ctx, "Wrote memory snapshot", slog.String("path", m.cfg.Snapshot))
+		}
+	}
+}
+
+func (m *MemoryStore) writeSnapshot() error {
+	m.mu.RLock()
+	snapshot := memorySnapshot{
+		Documents: slices.Collect(maps.Values(m.documents)),
+		Aliases:   m.aliases,
+		Webhooks:  slices.Collect(maps.Values(m.webhooks)),
+		Sequence:  m.sequence.Load(),
+	}
+	data, err := json.Marshal(snapshot)
+	m.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode memory snapshot: %w", err)
+	}
+
+	// write to a temporary file first, so a crash while writing doesn't destroy the old snapshot
+	tmp, err := os.CreateTemp(filepath.Dir(m.cfg.Snapshot), filepath.Base(m.cfg.Snapshot)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create memory snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), m.cfg.Snapshot); err != nil {
+		return fmt.Errorf("failed to write memory snapshot: %w", err)
+	}
+	return nil
+}
+
+// dispatch queues the event until the lock is released.
+func (m *MemoryStore) dispatch(event Event) {
+	m.pending = append(m.pending, event)
+}
+
+// unlock releases the lock and dispatches all pending events afterward,
+// so a full event queue or a subscriber using the store can't block while the lock is held.
+// Events of concurrent changes may therefore be dispatched in a different order than the changes were made.
+func (m *MemoryStore) unlock() {
+	events := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	for _, event := range events {
+		m.events.dispatch(event)
+	}
+}
+
+func (m *MemoryStore) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.unlock()
+
+	m.jobsCancel()
+	m.jobsWaitGroup.Wait()
+	// subscribers may still use the store while the queued events are dispatched
+	m.events.close()
+
+	if m.cfg.Snapshot == "" {
+		return nil
+	}
+
+	if err := m.writeSnapshot(); err != nil {
+		return err
+	}
+	slog.Info("Wrote memory snapshot", slog.String("path", m.cfg.Snapshot), slog.Int("documents", len(m.documents)))
+	return nil
+}
+
+func documentSize(document *memoryDocument) int64 {
+	var size int64
+	for _, files := range document.Versions {
+		size += filesSize(files)
+	}
+	return size
+}
+
+func filesSize(files []File) int64 {
+	var size int64
+	for _, file := range files {
+		size += int64(len(file.Content))
+	}
+	return size
+}
+
+// evict removes the documents in the trash and then the oldest documents except keepID until the total size is below the max size.
+// If that is not enough, the oldest versions of keepID are removed as well.
+func (m *MemoryStore) evict(keepID string) {
+	if m.cfg.MaxSize <= 0 || m.size <= m.cfg.MaxSize {
+		return
+	}
+
+	documents := slices.SortedFunc(maps.Values(m.documents), func(a *memoryDocument, b *memoryDocument) int {
+		return cmp.Or(compareDeleted(a, b), a.CreatedAt.Compare(b.CreatedAt))
+	})
+	for _, document := range documents {
+		if m.size <= m.cfg.MaxSize {
+			return
+		}
+		if document.ID == keepID {
+			continue
+		}
+		if document.DeletedAt != nil {
+			// the delete event was already sent when the document was trashed
+			m.purgeDocument(document.ID)
+			slog.Debug("Evicted deleted document from memory database", slog.String("document_id", document.ID))
+			continue
+		}
+		m.deleteDocument(document.ID)
+		// the webhooks are kept, so the server can send the delete event to them
+		m.dispatch(Event{Type: EventTypeDelete, DocumentID: document.ID, Version: document.LatestVersion, Evicted: &Document{
+			ID:      document.ID,
+			Version: document.LatestVersion,
+			Files:   cloneFiles(document.Versions[document.LatestVersion], true),
+		}})
+		slog.Debug("Evicted document from memory database", slog.String("document_id", document.ID))
+	}
+
+	document, ok := m.documents[keepID]
+	if !ok {
+		return
+	}
+	versions := slices.Sorted(maps.Keys(document.Versions))
+	for _, version := range versions[:len(versions)-1] {
+		if m.size <= m.cfg.MaxSize {
+			return
+		}
+		m.removeFiles(document, func(v int64, _ File) bool {
+			return v == version
+		})
+		slog.Debug("Evicted document version from memory database", slog.String("document_id", document.ID), slog.Int64("version", version))
+	}
+}
+
+// compareDeleted sorts documents in the trash before all other documents.
+func compareDeleted(a *memoryDocument, b *memoryDocument) int {
+	switch {
+	case a.DeletedAt != nil && b.DeletedAt == nil:
+		return -1
+	case a.DeletedAt == nil && b.DeletedAt != nil:
+		return 1
+	}
+	return 0
+}
+
+func (m *MemoryStore) deleteDocument(documentID string) {
+	document, ok := m.documents[documentID]
+	if !ok {
+		return
+	}
+	m.size -= document.Size
+	delete(m.documents, documentID)
+	for alias, a := range m.aliases {
+		if a.DocumentID == documentID {
+			delete(m.aliases, alias)
+		}
+	}
+}
+
+func (d *memoryDocument) setInfo(version int64, info VersionInfo) {
+	if info == (VersionInfo{}) {
+		return
+	}
+	if d.Infos == nil {
+		d.Infos = make(map[int64]VersionInfo)
+	}
+	d.Infos[version] = info
+}
+
+// pruneVersions removes versions without any files left and moves the latest version pointer of the document.
+// If no version is left, the document is removed as well.
+func (m *MemoryStore) pruneVersions(document *memoryDocument) {
+	for version, files := range document.Versions {
+		if len(files) == 0 {
+			delete(document.Versions, version)
+			delete(document.Infos, version)
+		}
+	}
+	if len(document.Versions) == 0 {
+		m.deleteDocument(document.ID)
+		return
+	}
+	document.LatestVersion = slices.Max(slices.Collect(maps.Keys(document.Versions)))
+}
+
+// dispatchChange dispatches an update event if the document still exists after removing files, or a delete event otherwise.
+func (m *MemoryStore) dispatchChange(document *memoryDocument) {
+	event := Event{Type: EventTypeUpdate, DocumentID: document.ID, Version: document.LatestVersion}
+	if _, ok := m.documents[document.ID]; !ok {
+		event.Type = EventTypeDelete
+	}
+	m.dispatch(event)
+}
+
+// removeFiles removes all files of the document matching the filter and updates the sizes.
+func (m *MemoryStore) removeFiles(document *memoryDocument, filter func(version int64, file File) bool) {
+	for version, files := range document.Versions {
+		kept := slices.DeleteFunc(slices.Clone(files), func(file File) bool {
+			return filter(version, file)
+		})
+		removed := filesSize(files) - filesSize(kept)
+		document.Size -= removed
+		m.size -= removed
+		document.Versions[version] = kept
+	}
+	m.pruneVersions(document)
+}
+
+func (m *MemoryStore) slugAvailable(slug string) bool {
+	if _, ok := m.documents[slug]; ok {
+		return false
+	}
+	_, ok := m.aliases[slug]
+	return !ok
+}
+
+// cloneFiles returns a copy of files, optionally without their content.
+func cloneFiles(files []File, withContent bool) []File {
+	cloned := slices.Clone(files)
+	if !withContent {
+		for i := range cloned {
+			cloned[i].Content = ""
+		}
+	}
+	return cloned
+}
+
+func (m *MemoryStore) getVersion(documentID string, version int64) ([]File, error) {
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	files, ok := document.Versions[version]
+	if !ok || len(files) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return cloneFiles(files, true), nil
+}
+
+func (m *MemoryStore) GetDocument(_ context.Context, documentID string) ([]File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return m.getVersion(documentID, document.LatestVersion)
+}
+
+func (m *MemoryStore) CreateDocument(ctx context.Context, files []File, slug string, info VersionInfo) (*string, *int64, error) {
+	size := filesSize(files)
+	if m.cfg.MaxSize > 0 && size > m.cfg.MaxSize {
+		return nil, nil, ErrMemoryFull
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	version := now.UnixMilli()
+
+	insert := func(documentID string) error {
+		if !m.slugAvailable(documentID) {
+			return ErrSlugTaken
+		}
+		for i := range files {
+			files[i].DocumentID = documentID
+			files[i].DocumentVersion = version
+			files[i].ContentHash = hashContent(files[i].Content)
+		}
+		m.documents[documentID] = &memoryDocument{
+			ID:            documentID,
+			LatestVersion: version,
+			CreatedAt:     now,
+			Versions:      map[int64][]File{version: cloneFiles(files, true)},
+			Size:          size,
+		}
+		m.documents[documentID].setInfo(version, info)
+		m.size += size
+		m.dispatch(Event{Type: EventTypeCreate, DocumentID: documentID, Version: version})
+		m.evict(documentID)
+		return nil
+	}
+
+	if slug != "" {
+		if err := insert(slug); err != nil {
+			return nil, nil, fmt.Errorf("failed to create document: %w", err)
+		}
+		return &slug, &version, nil
+	}
+
+	documentID, err := withNewID(ctx, m.documentIDs, m.cfg.IDs.MaxRetries, func(documentID string) error {
+		if err := insert(documentID); err != nil {
+			if errors.Is(err, ErrSlugTaken) {
+				return errIDTaken
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create document: %w", err)
+	}
+	return &documentID, &version, nil
+}
+
+func (m *MemoryStore) UpdateDocument(_ context.Context, documentID string, files []File, info VersionInfo) (*int64, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	size := filesSize(files)
+	if m.cfg.MaxSize > 0 && size > m.cfg.MaxSize {
+		return nil, ErrMemoryFull
+	}
+
+	version := time.Now().UnixMilli()
+	if version <= document.LatestVersion {
+		version = document.LatestVersion + 1
+	}
+	for i := range files {
+		files[i].DocumentID = documentID
+		files[i].DocumentVersion = version
+		files[i].ContentHash = hashContent(files[i].Content)
+	}
+
+	document.Versions[version] = cloneFiles(files, true)
+	document.setInfo(version, info)
+	document.LatestVersion = version
+	document.Size += size
+	m.size += size
+	m.dispatch(Event{Type: EventTypeUpdate, DocumentID: documentID, Version: version})
+	m.evict(documentID)
+	return &version, nil
+}
+
+func (m *MemoryStore) DeleteDocument(_ context.Context, documentID string) (*Document, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to delete document: %w", sql.ErrNoRows)
+	}
+	m.deleteDocument(documentID)
+	m.dispatch(Event{Type: EventTypeDelete, DocumentID: documentID, Version: document.LatestVersion})
+
+	return &Document{
+		ID:      documentID,
+		Version: document.LatestVersion,
+		Files:   cloneFiles(document.Versions[document.LatestVersion], true),
+	}, nil
+}
+
+func (m *MemoryStore) DeleteExpiredDocuments(_ context.Context, expireAfter time.Duration) ([]Document, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	expired := func(version int64, file File) bool {
+		if file.ExpiresAt != nil && file.ExpiresAt.Before(now) {
+			return true
+		}
+		return expireAfter > 0 && version < now.Add(-expireAfter).UnixMilli()
+	}
+
+	var documents []Document
+	for _, document := range slices.Collect(maps.Values(m.documents)) {
+		// only the files of the newest version with expired files are reported
+		var deleted *Document
+		for version, files := range document.Versions {
+			if deleted != nil && version < deleted.Version {
+				continue
+			}
+			var expiredFiles []File
+			for _, file := range files {
+				if expired(version, file) {
+					expiredFiles = append(expiredFiles, file)
+				}
+			}
+			if len(expiredFiles) > 0 {
+				deleted = &Document{
+					ID:      document.ID,
+					Version: version,
+					Files:   expiredFiles,
+				}
+			}
+		}
+		if deleted == nil {
+			continue
+		}
+
+		m.removeFiles(document, expired)
+		documents = append(documents, *deleted)
+		m.dispatchChange(document)
+	}
+
+	// the max size can be exceeded by a snapshot loaded with a smaller max size
+	m.evict("")
+	return documents, nil
+}
+
+func (m *MemoryStore) GetDocumentVersion(_ context.Context, documentID string, documentVersion int64) ([]File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getVersion(documentID, documentVersion)
+}
+
+func (m *MemoryStore) GetVersionCount(_ context.Context, documentID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return 0, nil
+	}
+	return len(document.Versions), nil
+}
+
+func (m *MemoryStore) GetDocumentVersions(_ context.Context, documentID string) ([]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, nil
+	}
+	versions := slices.Collect(maps.Keys(document.Versions))
+	slices.SortFunc(versions, func(a int64, b int64) int {
+		return cmp.Compare(b, a)
+	})
+	return versions, nil
+}
+
+func (m *MemoryStore) GetDocumentVersionsWithInfo(_ context.Context, documentID string) ([]Version, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, nil
+	}
+	versions := make([]Version, 0, len(document.Versions))
+	for version := range document.Versions {
+		versions = append(versions, Version{
+			Version:     version,
+			VersionInfo: document.Infos[version],
+		})
+	}
+	slices.SortFunc(versions, func(a Version, b Version) int {
+		return cmp.Compare(b.Version, a.Version)
+	})
+	return versions, nil
+}
+
+func (m *MemoryStore) GetDocumentVersionsWithFiles(_ context.Context, documentID string, withContent bool) (map[int64][]File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	versions := make(map[int64][]File, len(document.Versions))
+	for version, files := range document.Versions {
+		versions[version] = cloneFiles(files, withContent)
+	}
+	return versions, nil
+}
+
+func (m *MemoryStore) DeleteDocumentVersion(_ context.Context, documentID string, documentVersion int64) (*Document, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	files, err := m.getVersion(documentID, documentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete document version: %w", err)
+	}
+	document := m.documents[documentID]
+	m.removeFiles(document, func(version int64, _ File) bool {
+		return version == documentVersion
+	})
+	m.dispatchChange(document)
+
+	return &Document{
+		ID:      documentID,
+		Version: documentVersion,
+		Files:   files,
+	}, nil
+}
+
+func (m *MemoryStore) DeleteDocumentVersions(_ context.Context, documentID string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.documents[documentID]; ok {
+		m.deleteDocument(documentID)
+		m.dispatch(Event{Type: EventTypeDelete, DocumentID: documentID})
+	}
+	return nil
+}
+
+func (m *MemoryStore) getFile(documentID string, documentVersion int64, fileName string) (*File, error) {
+	files, err := m.getVersion(documentID, documentVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.Name == fileName {
+			return &file, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStore) GetDocumentFile(_ context.Context, documentID string, fileName string) (*File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get document file: %w", sql.ErrNoRows)
+	}
+	return m.getFile(documentID, document.LatestVersion, fileName)
+}
+
+func (m *MemoryStore) GetDocumentFileVersion(_ context.Context, documentID string, documentVersion int64, fileName string) (*File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.getFile(documentID, documentVersion, fileName)
+}
+
+func (m *MemoryStore) DeleteDocumentFile(_ context.Context, documentID string, fileName string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if document, ok := m.documents[documentID]; ok {
+		m.removeFiles(document, func(_ int64, file File) bool {
+			return file.Name == fileName
+		})
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteDocumentVersionFile(_ context.Context, documentID string, documentVersion int64, fileName string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if document, ok := m.documents[documentID]; ok {
+		m.removeFiles(document, func(version int64, file File) bool {
+			return version == documentVersion && file.Name == fileName
+		})
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetWebhook(_ context.Context, documentID string, webhookID string, secret string) (*Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	webhook, ok := m.webhooks[webhookID]
+	if !ok || webhook.DocumentID != documentID || webhook.Secret != secret {
+		return nil, sql.ErrNoRows
+	}
+	return &webhook, nil
+}
+
+func (m *MemoryStore) GetWebhooksByDocumentID(_ context.Context, documentID string) ([]Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var webhooks []Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.DocumentID == documentID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *MemoryStore) GetAndDeleteWebhooksByDocumentID(_ context.Context, documentID string) ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var webhooks []Webhook
+	for id, webhook := range m.webhooks {
+		if webhook.DocumentID == documentID {
+			webhooks = append(webhooks, webhook)
+			delete(m.webhooks, id)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *MemoryStore) CreateWebhook(ctx context.Context, documentID string, url string, secret string, events []string) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	webhook := Webhook{
+		DocumentID: documentID,
+		URL:        url,
+		Secret:     secret,
+		Events:     strings.Join(events, ","),
+	}
+
+	if _, err := withNewID(ctx, m.webhookIDs, m.cfg.IDs.MaxRetries, func(webhookID string) error {
+		if _, ok := m.webhooks[webhookID]; ok {
+			return errIDTaken
+		}
+		webhook.ID = webhookID
+		m.webhooks[webhookID] = webhook
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to insert webhook: %w", err)
+	}
+
+	return &webhook, nil
+}
+
+func (m *MemoryStore) UpdateWebhook(_ context.Context, documentID string, webhookID string, secret string, newURL string, newSecret string, newEvents []string) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	webhook, ok := m.webhooks[webhookID]
+	if !ok || webhook.DocumentID != documentID || webhook.Secret != secret {
+		return nil, sql.ErrNoRows
+	}
+
+	if newURL != "" {
+		webhook.URL = newURL
+	}
+	if newSecret != "" {
+		webhook.Secret = newSecret
+	}
+	if len(newEvents) > 0 {
+		webhook.Events = strings.Join(newEvents, ",")
+	}
+	m.webhooks[webhookID] = webhook
+	return &webhook, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(_ context.Context, documentID string, webhookID string, secret string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	webhook, ok := m.webhooks[webhookID]
+	if !ok || webhook.DocumentID != documentID || webhook.Secret != secret {
+		return sql.ErrNoRows
+	}
+	delete(m.webhooks, webhookID)
+	return nil
+}
+
+func (m *MemoryStore) ResolveDocumentID(_ context.Context, id string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	documentID := m.resolveDocumentID(id)
+	if document, ok := m.documents[documentID]; ok && document.DeletedAt != nil {
+		return "", ErrDocumentDeleted
+	}
+	return documentID, nil
+}
+
+func (m *MemoryStore) ResolveDocumentIDIncludingTrash(_ context.Context, id string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.resolveDocumentID(id), nil
+}
+
+func (m *MemoryStore) resolveDocumentID(id string) string {
+	if alias, ok := m.aliases[id]; ok {
+		return alias.DocumentID
+	}
+	return id
+}
+
+func (m *MemoryStore) SlugAvailable(_ context.Context, slug string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.slugAvailable(slug), nil
+}
+
+func (m *MemoryStore) GetDocumentAliases(_ context.Context, documentID string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var aliases []string
+	for alias, a := range m.aliases {
+		if a.DocumentID == documentID {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.SortFunc(aliases, func(a string, b string) int {
+		return m.aliases[a].CreatedAt.Compare(m.aliases[b].CreatedAt)
+	})
+	return aliases, nil
+}
+
+func (m *MemoryStore) CreateDocumentAlias(_ context.Context, documentID string, alias string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if _, ok := m.documents[documentID]; !ok {
+		return fmt.Errorf("failed to create document alias: %w", sql.ErrNoRows)
+	}
+	if !m.slugAvailable(alias) {
+		return fmt.Errorf("failed to create document alias: %w", ErrSlugTaken)
+	}
+	m.aliases[alias] = memoryAlias{
+		DocumentID: documentID,
+		CreatedAt:  time.Now(),
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteDocumentAlias(_ context.Context, documentID string, alias string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	a, ok := m.aliases[alias]
+	if !ok || a.DocumentID != documentID {
+		return sql.ErrNoRows
+	}
+	delete(m.aliases, alias)
+	return nil
+}
+
+func (m *MemoryStore) GetStorageSize(_ context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.size, nil
+}
+
+// BackfillContentSizes is a no-op, the memory store always knows the size of its contents.
+func (m *MemoryStore) BackfillContentSizes(_ context.Context, _ int) (int, error) {
+	return 0, nil
+}
+
+func (m *MemoryStore) GetEvictionCandidates(_ context.Context, policy EvictionPolicy, limit int) ([]DocumentSize, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var compare func(a *memoryDocument, b *memoryDocument) int
+	switch policy {
+	case EvictionPolicyOldest:
+		compare = func(a *memoryDocument, b *memoryDocument) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	case EvictionPolicyLeastRecentlyViewed:
+		viewedAt := func(document *memoryDocument) time.Time {
+			if document.ViewedAt != nil {
+				return *document.ViewedAt
+			}
+			return document.CreatedAt
+		}
+		compare = func(a *memoryDocument, b *memoryDocument) int {
+			return viewedAt(a).Compare(viewedAt(b))
+		}
+	case EvictionPolicyLargest:
+		compare = func(a *memoryDocument, b *memoryDocument) int {
+			return cmp.Compare(b.Size, a.Size)
+		}
+	default:
+		return nil, fmt.Errorf("unknown eviction policy: %s", policy)
+	}
+
+	documents := slices.SortedFunc(maps.Values(m.documents), func(a *memoryDocument, b *memoryDocument) int {
+		return cmp.Or(compareDeleted(a, b), compare(a, b), strings.Compare(a.ID, b.ID))
+	})
+	candidates := make([]DocumentSize, 0, min(limit, len(documents)))
+	for _, document := range documents[:min(limit, len(documents))] {
+		candidates = append(candidates, DocumentSize{
+			ID:      document.ID,
+			Size:    document.Size,
+			Deleted: document.DeletedAt != nil,
+		})
+	}
+	return candidates, nil
+}
+
+func (m *MemoryStore) TouchDocument(_ context.Context, documentID string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	if document, ok := m.documents[documentID]; ok {
+		now := time.Now()
+		document.ViewedAt = &now
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetDocumentRetention(_ context.Context, documentID string) (*RetentionPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to get document retention: %w", sql.ErrNoRows)
+	}
+	if document.Retention == nil {
+		return nil, nil
+	}
+	policy := *document.Retention
+	return &policy, nil
+}
+
+func (m *MemoryStore) SetDocumentRetention(_ context.Context, documentID string, policy *RetentionPolicy) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return fmt.Errorf("failed to set document retention: %w", sql.ErrNoRows)
+	}
+	if policy != nil {
+		p := *policy
+		policy = &p
+	}
+	document.Retention = policy
+	return nil
+}
+
+func (m *MemoryStore) ApplyRetentionPolicies(_ context.Context, defaultPolicy *RetentionPolicy) (int, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	now := time.Now()
+	var deleted int
+	for _, document := range slices.Collect(maps.Values(m.documents)) {
+		policy := document.Retention
+		if policy == nil {
+			policy = defaultPolicy
+		}
+		if policy == nil || len(document.Versions) < 2 {
+			continue
+		}
+
+		versions := slices.SortedFunc(maps.Keys(document.Versions), func(a int64, b int64) int {
+			return cmp.Compare(b, a)
+		})
+		expired := policy.expiredVersions(versions, now)
+		if len(expired) == 0 {
+			continue
+		}
+		expiredSet := make(map[int64]struct{}, len(expired))
+		for _, version := range expired {
+			expiredSet[version] = struct{}{}
+		}
+		m.removeFiles(document, func(version int64, _ File) bool {
+			_, ok := expiredSet[version]
+			return ok
+		})
+		deleted += len(expired)
+		m.dispatchChange(document)
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) TrashDocument(_ context.Context, documentID string) (*Document, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return nil, fmt.Errorf("failed to trash document: %w", sql.ErrNoRows)
+	}
+	if document.DeletedAt != nil {
+		return nil, fmt.Errorf("failed to trash document: %w", ErrDocumentDeleted)
+	}
+	now := time.Now()
+	document.DeletedAt = &now
+	m.dispatch(Event{Type: EventTypeDelete, DocumentID: documentID, Version: document.LatestVersion})
+
+	return &Document{
+		ID:      documentID,
+		Version: document.LatestVersion,
+		Files:   cloneFiles(document.Versions[document.LatestVersion], true),
+	}, nil
+}
+
+func (m *MemoryStore) RestoreDocument(_ context.Context, documentID string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return fmt.Errorf("failed to restore document: %w", sql.ErrNoRows)
+	}
+	if document.DeletedAt == nil {
+		return ErrDocumentNotDeleted
+	}
+	document.DeletedAt = nil
+	m.dispatch(Event{Type: EventTypeCreate, DocumentID: documentID, Version: document.LatestVersion})
+	return nil
+}
+
+func (m *MemoryStore) PurgeDeletedDocuments(_ context.Context, deletedBefore time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	var documentIDs []string
+	for _, document := range slices.Collect(maps.Values(m.documents)) {
+		if document.DeletedAt == nil || !document.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		m.purgeDocument(document.ID)
+		documentIDs = append(documentIDs, document.ID)
+	}
+	return documentIDs, nil
+}
+
+func (m *MemoryStore) PurgeDocument(_ context.Context, documentID string) error {
+	m.mu.Lock()
+	defer m.unlock()
+
+	document, ok := m.documents[documentID]
+	if !ok {
+		return fmt.Errorf("failed to purge document: %w", sql.ErrNoRows)
+	}
+	if document.DeletedAt == nil {
+		return fmt.Errorf("failed to purge document: %w", ErrDocumentNotDeleted)
+	}
+	m.purgeDocument(documentID)
+	return nil
+}
+
+// purgeDocument removes the document with its webhooks, which are kept while the document is in the trash.
+func (m *MemoryStore) purgeDocument(documentID string) {
+	m.deleteDocument(documentID)
+	for id, webhook := range m.webhooks {
+		if webhook.DocumentID == documentID {
+			delete(m.webhooks, id)
+		}
+	}
+}
+
+// AcquireLeadership always returns true, as the memory store can't be shared between instances.
+func (m *MemoryStore) AcquireLeadership(_ context.Context, _ time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (m *MemoryStore) ReleaseLeadership(_ context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Subscribe(fn func(event Event)) func() {
+	return m.events.Subscribe(fn)
+}
+
+func (m *MemoryStore) GetIDSequence(_ context.Context) (int64, error) {
+	return m.sequence.Load(), nil
+}
+
+func (m *MemoryStore) RestoreIDSequence(_ context.Context, value int64) error {
+	for {
+		current := m.sequence.Load()
+		if current >= value || m.sequence.CompareAndSwap(current, value) {
+			return nil
+		}
+	}
+}
+
+func (m *MemoryStore) ExportDocuments(ctx context.Context, fn func(document BackupDocument) error) error {
+	m.mu.Lock()
+	documents := make([]BackupDocument, 0, len(m.documents))
+	for _, document := range m.documents {
+		backupDocument := BackupDocument{
+			ID:        document.ID,
+			CreatedAt: document.CreatedAt,
+			ViewedAt:  document.ViewedAt,
+			DeletedAt: document.DeletedAt,
+			Retention: document.Retention,
+		}
+		for _, version := range slices.Sorted(maps.Keys(document.Versions)) {
+			info := document.Infos[version]
+			backupDocument.Versions = append(backupDocument.Versions, BackupVersion{
+				Version: version,
+				Message: info.Message,
+				Author:  info.Author,
+				Files:   newBackupFiles(document.Versions[version]),
+			})
+		}
+		for alias, memAlias := range m.aliases {
+			if memAlias.DocumentID == document.ID {
+				backupDocument.Aliases = append(backupDocument.Aliases, BackupAlias{
+					Alias:     alias,
+					CreatedAt: memAlias.CreatedAt,
+				})
+			}
+		}
+		for _, webhook := range m.webhooks {
+			if webhook.DocumentID == document.ID {
+				backupDocument.Webhooks = append(backupDocument.Webhooks, newBackupWebhook(webhook))
+			}
+		}
+		documents = append(documents, backupDocument)
+	}
+	m.unlock()
+
+	slices.SortFunc(documents, func(a BackupDocument, b BackupDocument) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	for _, document := range documents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ImportDocument(_ context.Context, document BackupDocument) error {
+	versions := document.sortedVersions()
+	if len(versions) == 0 {
+		return fmt.Errorf("failed to import document %s: no versions", document.ID)
+	}
+
+	m.mu.Lock()
+	defer m.unlock()
+
+	if !m.slugAvailable(document.ID) {
+		return fmt.Errorf("failed to import document %s: %w", document.ID, ErrSlugTaken)
+	}
+	for _, alias := range document.Aliases {
+		if alias.Alias == document.ID || !m.slugAvailable(alias.Alias) {
+			return fmt.Errorf("failed to import document %s: %w", document.ID, ErrSlugTaken)
+		}
+	}
+
+	memDocument := &memoryDocument{
+		ID:            document.ID,
+		LatestVersion: versions[len(versions)-1].Version,
+		CreatedAt:     document.CreatedAt,
+		ViewedAt:      document.ViewedAt,
+		Retention:     document.Retention,
+		DeletedAt:     document.DeletedAt,
+		Versions:      make(map[int64][]File, len(versions)),
+	}
+	for _, version := range versions {
+		memDocument.Versions[version.Version] = version.files(document.ID)
+		memDocument.setInfo(version.Version, version.info())
+	}
+	memDocument.Size = documentSize(memDocument)
+
+	m.documents[document.ID] = memDocument
+	m.size += memDocument.Size
+	for _, alias := range document.Aliases {
+		m.aliases[alias.Alias] = memoryAlias{
+			DocumentID: document.ID,
+			CreatedAt:  alias.CreatedAt,
+		}
+	}
+	for _, webhook := range document.Webhooks {
+		m.webhooks[webhook.ID] = Webhook{
+			ID:         webhook.ID,
+			DocumentID: document.ID,
+			URL:        webhook.URL,
+			Secret:     webhook.Secret,
+			Events:     strings.Join(webhook.Events, ","),
+		}
+	}
+	m.dispatch(Event{Type: EventTypeCreate, DocumentID: document.ID, Version: memDocument.LatestVersion})
+	m.evict(document.ID)
+	return nil
+}
+
+// ScrubContents re-verifies up to limit contents which have not been verified since verifiedBefore.
+// Like with the sql databases, a content is identified by its hash and all files sharing it are checked.
+// The contents only differ from their hashes if a snapshot has been modified.
+func (m *MemoryStore) ScrubContents(_ context.Context, verifiedBefore time.Time, limit int) (*ScrubResult, error) {
+	m.mu.Lock()
+	defer m.unlock()
+
+	contents := make(map[string][]File)
+	for _, document := range m.documents {
+		for _, files := range document.Versions {
+			for _, file := range files {
+				contents[file.ContentHash] = append(contents[file.ContentHash], file)
+			}
+		}
+	}
+
+	// forget contents which are gone
+	maps.DeleteFunc(m.verified, func(hash string, _ time.Time) bool {
+		_, ok := contents[hash]
+		return !ok
+	})
+	m.mismatches = slices.DeleteFunc(m.mismatches, func(mismatch ContentMismatch) bool {
+		_, ok := contents[mismatch.ContentHash]
+		return !ok
+	})
+
+	var hashes []string
+	for _, hash := range slices.Sorted(maps.Keys(contents)) {
+		if len(hashes) >= limit {
+			break
+		}
+		if verifiedAt, ok := m.verified[hash]; !ok || verifiedAt.Before(verifiedBefore) {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	now := time.Now()
+	result := &ScrubResult{}
+	for _, hash := range hashes {
+		m.verified[hash] = now
+		m.mismatches = slices.DeleteFunc(m.mismatches, func(mismatch ContentMismatch) bool {
+			return mismatch.ContentHash == hash
+		})
+		for _, file := range contents[hash] {
+			if actual := hashContent(file.Content); actual != file.ContentHash {
+				result.Mismatches = append(result.Mismatches, ContentMismatch{
+					DocumentID:      file.DocumentID,
+					DocumentVersion: file.DocumentVersion,
+					FileName:        file.Name,
+					ContentHash:     file.ContentHash,
+					Error:           fmt.Sprintf("content hash mismatch: got %s", actual),
+					VerifiedAt:      now,
+				})
+			}
+		}
+		result.Checked++
+	}
+	m.mismatches = append(m.mismatches, result.Mismatches...)
+	slices.SortFunc(m.mismatches, func(a ContentMismatch, b ContentMismatch) int {
+		return cmp.Or(
+			strings.Compare(a.DocumentID, b.DocumentID),
+			cmp.Compare(a.DocumentVersion, b.DocumentVersion),
+			strings.Compare(a.FileName, b.FileName),
+		)
+	})
+	return result, nil
+}
+
+func (m *MemoryStore) GetContentMismatches(_ context.Context) ([]ContentMismatch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.mismatches), nil
+}
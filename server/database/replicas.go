@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/topi314/tint"
+
+	"github.com/topi314/gobin/v2/internal/timex"
+)
+
+type ReadReplicaConfig struct {
+	DSNs                 []string       `toml:"dsns"`
+	ReadYourWritesWindow timex.Duration `toml:"read_your_writes_window"`
+}
+
+func (c ReadReplicaConfig) String() string {
+	dsns := make([]string, len(c.DSNs))
+	for i, dsn := range c.DSNs {
+		dsns[i] = strings.Repeat("*", len(dsn))
+	}
+	return fmt.Sprintf("\n   DSNs: %s\n   ReadYourWritesWindow: %s",
+		strings.Join(dsns, ", "),
+		time.Duration(c.ReadYourWritesWindow),
+	)
+}
+
+type primaryKey struct{}
+
+// WithPrimary makes all reads using the returned context go to the primary database instead of a read replica.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// read runs fn on the next read replica and falls back to the primary if it fails,
+// which also covers documents which have not been replicated yet.
+// Without read replicas or with a context from WithPrimary, fn is only run on the primary.
+func (d *DB) read(ctx context.Context, fn func(q *sqlx.DB) error) error {
+	if len(d.replicas) == 0 || usePrimary(ctx) {
+		return fn(d.DB)
+	}
+
+	replica := d.replicas[d.nextReplica.Add(1)%uint64(len(d.replicas))]
+	err := fn(replica)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		slog.WarnContext(ctx, "failed to read from replica, falling back to primary", tint.Err(err))
+	}
+	return fn(d.DB)
+}
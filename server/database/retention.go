@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/topi314/gobin/v2/internal/timex"
+)
+
+const (
+	// retentionDeleteBatchSize is how many versions are deleted per query at most.
+	retentionDeleteBatchSize = 500
+	// retentionCandidatesBatchSize is how many documents are read per query while applying retention policies.
+	retentionCandidatesBatchSize = 100
+)
+
+// RetentionPolicy decides which old versions of a document are kept.
+// A version is removed as soon as one of the rules drops it, rules set to 0 are disabled.
+// The latest version is always kept.
+type RetentionPolicy struct {
+	// KeepLast keeps only the newest versions.
+	KeepLast int `toml:"keep_last" json:"keep_last"`
+	// MaxAge drops versions older than the duration, except the first version.
+	MaxAge timex.Duration `toml:"max_age" json:"max_age"`
+	// ThinAfter keeps only the newest version per day for versions older than the duration, except the first version.
+	ThinAfter timex.Duration `toml:"thin_after" json:"thin_after"`
+}
+
+func (p RetentionPolicy) String() string {
+	return fmt.Sprintf("\n  KeepLast: %d\n  MaxAge: %s\n  ThinAfter: %s",
+		p.KeepLast,
+		time.Duration(p.MaxAge),
+		time.Duration(p.ThinAfter),
+	)
+}
+
+// Validate returns an error if any rule of the policy is negative.
+func (p RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 {
+		return fmt.Errorf("invalid retention keep_last: %d", p.KeepLast)
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("invalid retention max_age: %s", time.Duration(p.MaxAge))
+	}
+	if p.ThinAfter < 0 {
+		return fmt.Errorf("invalid retention thin_after: %s", time.Duration(p.ThinAfter))
+	}
+	return nil
+}
+
+// expiredVersions returns the versions which are not kept by the policy.
+// The versions have to be sorted from newest to oldest.
+func (p RetentionPolicy) expiredVersions(versions []int64, now time.Time) []int64 {
+	if len(versions) < 2 {
+		return nil
+	}
+
+	var (
+		first     = versions[len(versions)-1]
+		maxAge    = now.Add(-time.Duration(p.MaxAge)).UnixMilli()
+		thinAfter = now.Add(-time.Duration(p.ThinAfter)).UnixMilli()
+		dayMs     = (24 * time.Hour).Milliseconds()
+		keptDay   = versions[0] / dayMs
+		expired   []int64
+	)
+	for i, version := range versions[1:] {
+		day := version / dayMs
+		drop := p.KeepLast > 0 && i+1 >= p.KeepLast ||
+			version != first && (p.MaxAge > 0 && version < maxAge || p.ThinAfter > 0 && version < thinAfter && day == keptDay)
+		if !drop {
+			keptDay = day
+			continue
+		}
+		expired = append(expired, version)
+	}
+	return expired
+}
+
+type documentRetention struct {
+	ID        string        `db:"id"`
+	KeepLast  sql.NullInt64 `db:"retention_keep_last"`
+	MaxAge    sql.NullInt64 `db:"retention_max_age"`
+	ThinAfter sql.NullInt64 `db:"retention_thin_after"`
+}
+
+func (r documentRetention) policy() *RetentionPolicy {
+	if !r.KeepLast.Valid {
+		return nil
+	}
+	return &RetentionPolicy{
+		KeepLast:  int(r.KeepLast.Int64),
+		MaxAge:    timex.Duration(r.MaxAge.Int64),
+		ThinAfter: timex.Duration(r.ThinAfter.Int64),
+	}
+}
+
+// GetDocumentRetention returns the own retention policy of the document or nil if it uses the server policy.
+func (d *DB) GetDocumentRetention(ctx context.Context, documentID string) (*RetentionPolicy, error) {
+	var retention documentRetention
+	if err := d.GetContext(ctx, &retention, d.Rebind("SELECT id, retention_keep_last, retention_max_age, retention_thin_after FROM documents WHERE id = ?;"), documentID); err != nil {
+		return nil, fmt.Errorf("failed to get document retention: %w", err)
+	}
+	return retention.policy(), nil
+}
+
+// SetDocumentRetention sets the own retention policy of the document, nil resets it to the server policy.
+func (d *DB) SetDocumentRetention(ctx context.Context, documentID string, policy *RetentionPolicy) error {
+	var keepLast, maxAge, thinAfter sql.NullInt64
+	if policy != nil {
+		keepLast = sql.NullInt64{Int64: int64(policy.KeepLast), Valid: true}
+		maxAge = sql.NullInt64{Int64: int64(policy.MaxAge), Valid: true}
+		thinAfter = sql.NullInt64{Int64: int64(policy.ThinAfter), Valid: true}
+	}
+
+	res, err := d.ExecContext(ctx, d.Rebind("UPDATE documents SET retention_keep_last = ?, retention_max_age = ?, retention_thin_after = ? WHERE id = ?;"), keepLast, maxAge, thinAfter, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to set document retention: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to set document retention: %w", err)
+	} else if rows == 0 {
+		return fmt.Errorf("failed to set document retention: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// retentionCandidatesQuery selects the documents with versions which could be removed by their retention policy.
+// The first version of a document is excluded from the aggregates, since no rule besides keep_last removes it.
+// The conditions are a cheap superset of what expiredVersions removes, documents with a version on the day
+// thin_after ends might be returned without anything to remove.
+const retentionCandidatesQuery = `SELECT d.id, d.retention_keep_last, d.retention_max_age, d.retention_thin_after
+FROM (
+	SELECT id, retention_keep_last, retention_max_age, retention_thin_after,
+		COALESCE(retention_keep_last, ?) AS keep_last,
+		COALESCE(retention_max_age, ?) / 1000000 AS max_age,
+		COALESCE(retention_thin_after, ?) / 1000000 AS thin_after
+	FROM documents
+	WHERE id > ? %s
+) d
+JOIN versions v ON v.document_id = d.id
+WHERE v.version > (SELECT MIN(version) FROM versions WHERE document_id = d.id)
+GROUP BY d.id, d.retention_keep_last, d.retention_max_age, d.retention_thin_after, d.keep_last, d.max_age, d.thin_after
+HAVING d.keep_last > 0 AND COUNT(*) >= d.keep_last
+	OR d.max_age > 0 AND MIN(v.version) < ? - d.max_age AND (COUNT(*) > 1 OR MAX(v.version) >= ? - d.max_age)
+	OR d.thin_after > 0 AND SUM(CASE WHEN v.version < ? - d.thin_after + 86400000 THEN 1 ELSE 0 END) > COUNT(DISTINCT CASE WHEN v.version < ? - d.thin_after + 86400000 THEN v.version - v.version %% 86400000 END)
+ORDER BY d.id
+LIMIT ?;`
+
+// ApplyRetentionPolicies removes all versions which are not kept by the retention policy of their document,
+// or by the default policy if the document has none. It returns the number of removed versions.
+func (d *DB) ApplyRetentionPolicies(ctx context.Context, defaultPolicy *RetentionPolicy) (int, error) {
+	var (
+		where                       string
+		keepLast, maxAge, thinAfter int64
+	)
+	if defaultPolicy == nil {
+		where = "AND retention_keep_last IS NOT NULL"
+	} else {
+		keepLast = int64(defaultPolicy.KeepLast)
+		maxAge = int64(defaultPolicy.MaxAge)
+		thinAfter = int64(defaultPolicy.ThinAfter)
+	}
+	query := d.Rebind(fmt.Sprintf(retentionCandidatesQuery, where))
+
+	now := time.Now()
+	nowMs := now.UnixMilli()
+	var (
+		deleted int
+		lastID  string
+	)
+	for {
+		var documents []documentRetention
+		if err := d.SelectContext(ctx, &documents, query, keepLast, maxAge, thinAfter, lastID, nowMs, nowMs, nowMs, nowMs, retentionCandidatesBatchSize); err != nil {
+			return deleted, fmt.Errorf("failed to get documents for retention: %w", err)
+		}
+
+		for _, document := range documents {
+			n, err := d.applyRetentionPolicy(ctx, document, defaultPolicy, now)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+		if len(documents) < retentionCandidatesBatchSize {
+			break
+		}
+		lastID = documents[len(documents)-1].ID
+	}
+
+	if deleted > 0 {
+		if err := d.deleteOrphanedContents(ctx); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (d *DB) applyRetentionPolicy(ctx context.Context, document documentRetention, defaultPolicy *RetentionPolicy, now time.Time) (int, error) {
+	policy := document.policy()
+	if policy == nil {
+		policy = defaultPolicy
+	}
+
+	versions, err := d.GetDocumentVersions(ctx, document.ID)
+	if err != nil {
+		return 0, err
+	}
+	expired := policy.expiredVersions(versions, now)
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	if err = d.withTx(ctx, func(tx *sqlx.Tx) error {
+		for chunk := range slices.Chunk(expired, retentionDeleteBatchSize) {
+			query, args, err := sqlx.In("DELETE FROM files WHERE document_id = ? AND document_version IN (?);", document.ID, chunk)
+			if err != nil {
+				return err
+			}
+			if _, err = tx.ExecContext(ctx, tx.Rebind(query), args...); err != nil {
+				return err
+			}
+		}
+		return pruneVersions(ctx, tx, document.ID)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to apply retention policy to document %s: %w", document.ID, err)
+	}
+	d.publish(ctx, Event{Type: EventTypeUpdate, DocumentID: document.ID, Version: versions[0]})
+	return len(expired), nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/topi314/gobin/v2/internal/timex"
+)
+
+func TestRetentionPolicyExpiredVersions(t *testing.T) {
+	now := time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+	at := func(daysAgo int, hour int) int64 {
+		return time.Date(2024, 6, 30-daysAgo, hour, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	days := func(n int) timex.Duration {
+		return timex.Duration(time.Duration(n) * 24 * time.Hour)
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		versions []int64
+		want     []int64
+	}{
+		{
+			name:     "no rules",
+			policy:   RetentionPolicy{},
+			versions: []int64{at(0, 10), at(50, 10), at(100, 10)},
+			want:     nil,
+		},
+		{
+			name:     "single version",
+			policy:   RetentionPolicy{KeepLast: 1, MaxAge: days(1), ThinAfter: days(1)},
+			versions: []int64{at(100, 10)},
+			want:     nil,
+		},
+		{
+			name:     "keep last",
+			policy:   RetentionPolicy{KeepLast: 2},
+			versions: []int64{at(0, 10), at(1, 10), at(2, 10), at(3, 10)},
+			want:     []int64{at(2, 10), at(3, 10)},
+		},
+		{
+			name:     "keep last with fewer versions",
+			policy:   RetentionPolicy{KeepLast: 5},
+			versions: []int64{at(0, 10), at(1, 10), at(2, 10)},
+			want:     nil,
+		},
+		{
+			name:     "max age keeps newer versions",
+			policy:   RetentionPolicy{MaxAge: days(10)},
+			versions: []int64{at(0, 10), at(5, 10), at(20, 10), at(30, 10), at(40, 10)},
+			want:     []int64{at(20, 10), at(30, 10)},
+		},
+		{
+			name:     "max age keeps first and latest version",
+			policy:   RetentionPolicy{MaxAge: days(10)},
+			versions: []int64{at(20, 10), at(30, 10), at(40, 10)},
+			want:     []int64{at(30, 10)},
+		},
+		{
+			name:     "thin after keeps the newest version per day",
+			policy:   RetentionPolicy{ThinAfter: days(10)},
+			versions: []int64{at(20, 18), at(20, 12), at(20, 8), at(21, 12), at(22, 18), at(22, 8), at(30, 8)},
+			want:     []int64{at(20, 12), at(20, 8), at(22, 8)},
+		},
+		{
+			name:     "thin after keeps newer versions",
+			policy:   RetentionPolicy{ThinAfter: days(10)},
+			versions: []int64{at(0, 18), at(0, 12), at(0, 8), at(20, 8)},
+			want:     nil,
+		},
+		{
+			name:     "thin after keeps first version",
+			policy:   RetentionPolicy{ThinAfter: days(10)},
+			versions: []int64{at(0, 8), at(20, 18), at(20, 12), at(20, 8)},
+			want:     []int64{at(20, 12)},
+		},
+		{
+			name:     "keep last drops first version",
+			policy:   RetentionPolicy{KeepLast: 2, MaxAge: days(10)},
+			versions: []int64{at(0, 10), at(1, 10), at(20, 10)},
+			want:     []int64{at(20, 10)},
+		},
+		{
+			name:     "rules combined",
+			policy:   RetentionPolicy{KeepLast: 5, MaxAge: days(30), ThinAfter: days(10)},
+			versions: []int64{at(0, 10), at(20, 18), at(20, 8), at(40, 10), at(50, 10), at(60, 10), at(70, 10)},
+			want:     []int64{at(20, 8), at(40, 10), at(50, 10), at(60, 10), at(70, 10)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.expiredVersions(tt.versions, now); !slices.Equal(got, tt.want) {
+				t.Errorf("expiredVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// ContentMismatch is a file whose stored content could not be read or does not match its content hash anymore.
+type ContentMismatch struct {
+	DocumentID      string    `db:"document_id"`
+	DocumentVersion int64     `db:"document_version"`
+	FileName        string    `db:"name"`
+	ContentHash     string    `db:"content_hash"`
+	Error           string    `db:"verify_error"`
+	VerifiedAt      time.Time `db:"verified_at"`
+}
+
+// ScrubResult is the outcome of a single ScrubContents run.
+type ScrubResult struct {
+	Checked    int
+	Mismatches []ContentMismatch
+}
+
+// Digest returns the content hash in the format of the Digest header.
+func Digest(contentHash string) string {
+	sum, err := hex.DecodeString(contentHash)
+	if err != nil || len(sum) != sha256.Size {
+		return ""
+	}
+	return "sha-256=" + base64.StdEncoding.EncodeToString(sum)
+}
+
+// ScrubContents re-verifies up to limit contents which have not been verified since verifiedBefore.
+// Every content is read like it would be served, including blobs, decryption and delta chains, and hashed again.
+// The outcome is stored with the content, so mismatches are reported by GetContentMismatches until the content verifies again.
+func (d *DB) ScrubContents(ctx context.Context, verifiedBefore time.Time, limit int) (*ScrubResult, error) {
+	var hashes []string
+	if err := d.SelectContext(ctx, &hashes, d.Rebind("SELECT hash FROM contents WHERE verified_at IS NULL OR verified_at < ? LIMIT ?;"), verifiedBefore, limit); err != nil {
+		return nil, fmt.Errorf("failed to get contents to scrub: %w", err)
+	}
+
+	result := &ScrubResult{}
+	var mismatches []string
+	for _, hash := range hashes {
+		verifyErr := d.verifyContent(ctx, hash)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		var verifyError *string
+		if verifyErr != nil {
+			errStr := verifyErr.Error()
+			verifyError = &errStr
+			mismatches = append(mismatches, hash)
+		}
+		if _, err := d.ExecContext(ctx, d.Rebind("UPDATE contents SET verified_at = ?, verify_error = ? WHERE hash = ?;"), time.Now(), verifyError, hash); err != nil {
+			return nil, fmt.Errorf("failed to update content verification: %w", err)
+		}
+		result.Checked++
+	}
+
+	for _, hash := range mismatches {
+		files, err := d.getContentMismatches(ctx, "c.hash = ?", hash)
+		if err != nil {
+			return nil, err
+		}
+		result.Mismatches = append(result.Mismatches, files...)
+	}
+	return result, nil
+}
+
+// GetContentMismatches returns all files whose content failed its last verification.
+func (d *DB) GetContentMismatches(ctx context.Context) ([]ContentMismatch, error) {
+	return d.getContentMismatches(ctx, "c.verify_error IS NOT NULL")
+}
+
+func (d *DB) getContentMismatches(ctx context.Context, where string, args ...any) ([]ContentMismatch, error) {
+	var mismatches []ContentMismatch
+	if err := d.SelectContext(ctx, &mismatches, d.Rebind("SELECT f.document_id, f.document_version, f.name, f.content_hash, c.verify_error, c.verified_at FROM contents c JOIN files f ON f.content_hash = c.hash WHERE "+where+" ORDER BY f.document_id, f.document_version, f.order_index;"), args...); err != nil {
+		return nil, fmt.Errorf("failed to get content mismatches: %w", err)
+	}
+	return mismatches, nil
+}
+
+// verifyContent resolves the content with the given hash and returns an error if it can't be read or its hash differs.
+func (d *DB) verifyContent(ctx context.Context, hash string) error {
+	contents, err := d.getContents(ctx, d.DB, []string{hash})
+	if err != nil {
+		return err
+	}
+	content, err := resolveContent(contents, make(map[string][]byte), hash, 0)
+	if err != nil {
+		return err
+	}
+	if actual := hashContent(string(content)); actual != hash {
+		return fmt.Errorf("content hash mismatch: got %s", actual)
+	}
+	return nil
+}
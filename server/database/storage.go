@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// viewedAtResolution is how often the last view time of a document is updated at most.
+const viewedAtResolution = time.Hour
+
+type EvictionPolicy string
+
+const (
+	EvictionPolicyOldest              EvictionPolicy = "oldest"
+	EvictionPolicyLeastRecentlyViewed EvictionPolicy = "least_recently_viewed"
+	EvictionPolicyLargest             EvictionPolicy = "largest"
+)
+
+// DocumentSize is the stored size of all contents referenced by a document.
+// Contents shared with other documents are counted for each of them,
+// so removing a document may free less storage than its size.
+// Use GetStorageSize to find out how much storage was actually freed.
+type DocumentSize struct {
+	ID   string `db:"id"`
+	Size int64  `db:"size"`
+	// Deleted is true if the document is in the trash.
+	Deleted bool `db:"deleted"`
+}
+
+// GetStorageSize returns the stored size of all contents which are still referenced by a file.
+// Orphaned contents are not counted, as they are removed on the next cleanup anyway.
+func (d *DB) GetStorageSize(ctx context.Context) (int64, error) {
+	var size int64
+	if err := d.GetContext(ctx, &size, "SELECT COALESCE(SUM(size), 0) FROM contents WHERE size > 0 AND (hash IN (SELECT content_hash FROM files) OR hash IN (SELECT base_hash FROM contents WHERE base_hash IS NOT NULL));"); err != nil {
+		return 0, fmt.Errorf("failed to get storage size: %w", err)
+	}
+	return size, nil
+}
+
+// BackfillContentSizes sets the size of up to limit contents whose size is unknown by loading them from the blob store.
+// It returns the number of contents which got a size.
+func (d *DB) BackfillContentSizes(ctx context.Context, limit int) (int, error) {
+	var contents []Content
+	if err := d.SelectContext(ctx, &contents, d.Rebind("SELECT hash, blob_key FROM contents WHERE size < 0 AND blob_key IS NOT NULL LIMIT ?;"), limit); err != nil {
+		return 0, fmt.Errorf("failed to get contents without size: %w", err)
+	}
+
+	var backfilled int
+	for _, content := range contents {
+		data, err := d.loadBlob(ctx, *content.BlobKey)
+		if err != nil {
+			return backfilled, err
+		}
+		// only apply the size if the content has not been rewritten in the meantime
+		res, err := d.ExecContext(ctx, d.Rebind("UPDATE contents SET size = ? WHERE hash = ? AND blob_key = ? AND size < 0;"), len(data), content.Hash, *content.BlobKey)
+		if err != nil {
+			return backfilled, fmt.Errorf("failed to update content size: %w", err)
+		}
+		if rows, err := res.RowsAffected(); err == nil && rows > 0 {
+			backfilled++
+		}
+	}
+	return backfilled, nil
+}
+
+// GetEvictionCandidates returns up to limit documents in the order they should be evicted by the policy.
+// Documents in the trash are returned first.
+func (d *DB) GetEvictionCandidates(ctx context.Context, policy EvictionPolicy, limit int) ([]DocumentSize, error) {
+	var orderBy string
+	switch policy {
+	case EvictionPolicyOldest:
+		orderBy = "d.created_at"
+	case EvictionPolicyLeastRecentlyViewed:
+		orderBy = "COALESCE(d.viewed_at, d.created_at)"
+	case EvictionPolicyLargest:
+		orderBy = "size DESC"
+	default:
+		return nil, fmt.Errorf("unknown eviction policy: %s", policy)
+	}
+
+	var documents []DocumentSize
+	if err := d.SelectContext(ctx, &documents, d.Rebind("SELECT d.id, COALESCE(SUM(CASE WHEN c.size > 0 THEN c.size ELSE 0 END), 0) AS size, CASE WHEN d.deleted_at IS NULL THEN 0 ELSE 1 END AS deleted FROM documents d LEFT JOIN (SELECT DISTINCT document_id, content_hash FROM files) f ON f.document_id = d.id LEFT JOIN contents c ON c.hash = f.content_hash GROUP BY d.id, d.created_at, d.viewed_at, d.deleted_at ORDER BY deleted DESC, "+orderBy+", d.id LIMIT ?;"), limit); err != nil {
+		return nil, fmt.Errorf("failed to get eviction candidates: %w", err)
+	}
+	return documents, nil
+}
+
+// TouchDocument records that the document has been viewed.
+// The view time is only updated once per viewedAtResolution to avoid a write on every view.
+func (d *DB) TouchDocument(ctx context.Context, documentID string) error {
+	now := time.Now()
+	if _, err := d.ExecContext(ctx, d.Rebind("UPDATE documents SET viewed_at = ? WHERE id = ? AND (viewed_at IS NULL OR viewed_at < ?);"), now, documentID, now.Add(-viewedAtResolution)); err != nil {
+		return fmt.Errorf("failed to touch document: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Store is the storage backend used by the server.
+// Implementations return sql.ErrNoRows when a requested document, version, file or webhook does not exist.
+type Store interface {
+	DocumentStore
+	VersionStore
+	FileStore
+	WebhookStore
+	AliasStore
+	StorageStore
+	RetentionStore
+	TrashStore
+	LeaderStore
+	EventStore
+	BackupStore
+	ScrubStore
+
+	Close() error
+}
+
+type DocumentStore interface {
+	GetDocument(ctx context.Context, documentID string) ([]File, error)
+	CreateDocument(ctx context.Context, files []File, slug string, info VersionInfo) (*string, *int64, error)
+	UpdateDocument(ctx context.Context, documentID string, files []File, info VersionInfo) (*int64, error)
+	DeleteDocument(ctx context.Context, documentID string) (*Document, error)
+	DeleteExpiredDocuments(ctx context.Context, expireAfter time.Duration) ([]Document, error)
+}
+
+type VersionStore interface {
+	GetDocumentVersion(ctx context.Context, documentID string, documentVersion int64) ([]File, error)
+	GetVersionCount(ctx context.Context, documentID string) (int, error)
+	GetDocumentVersions(ctx context.Context, documentID string) ([]int64, error)
+	GetDocumentVersionsWithInfo(ctx context.Context, documentID string) ([]Version, error)
+	GetDocumentVersionsWithFiles(ctx context.Context, documentID string, withContent bool) (map[int64][]File, error)
+	DeleteDocumentVersion(ctx context.Context, documentID string, documentVersion int64) (*Document, error)
+	DeleteDocumentVersions(ctx context.Context, documentID string) error
+}
+
+type FileStore interface {
+	GetDocumentFile(ctx context.Context, documentID string, fileName string) (*File, error)
+	GetDocumentFileVersion(ctx context.Context, documentID string, documentVersion int64, fileName string) (*File, error)
+	DeleteDocumentFile(ctx context.Context, documentID string, fileName string) error
+	DeleteDocumentVersionFile(ctx context.Context, documentID string, documentVersion int64, fileName string) error
+}
+
+type WebhookStore interface {
+	GetWebhook(ctx context.Context, documentID string, webhookID string, secret string) (*Webhook, error)
+	GetWebhooksByDocumentID(ctx context.Context, documentID string) ([]Webhook, error)
+	GetAndDeleteWebhooksByDocumentID(ctx context.Context, documentID string) ([]Webhook, error)
+	CreateWebhook(ctx context.Context, documentID string, url string, secret string, events []string) (*Webhook, error)
+	UpdateWebhook(ctx context.Context, documentID string, webhookID string, secret string, newURL string, newSecret string, newEvents []string) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, documentID string, webhookID string, secret string) error
+}
+
+type AliasStore interface {
+	ResolveDocumentID(ctx context.Context, id string) (string, error)
+	ResolveDocumentIDIncludingTrash(ctx context.Context, id string) (string, error)
+	SlugAvailable(ctx context.Context, slug string) (bool, error)
+	GetDocumentAliases(ctx context.Context, documentID string) ([]string, error)
+	CreateDocumentAlias(ctx context.Context, documentID string, alias string) error
+	DeleteDocumentAlias(ctx context.Context, documentID string, alias string) error
+}
+
+type StorageStore interface {
+	GetStorageSize(ctx context.Context) (int64, error)
+	BackfillContentSizes(ctx context.Context, limit int) (int, error)
+	GetEvictionCandidates(ctx context.Context, policy EvictionPolicy, limit int) ([]DocumentSize, error)
+	TouchDocument(ctx context.Context, documentID string) error
+}
+
+type RetentionStore interface {
+	GetDocumentRetention(ctx context.Context, documentID string) (*RetentionPolicy, error)
+	SetDocumentRetention(ctx context.Context, documentID string, policy *RetentionPolicy) error
+	ApplyRetentionPolicies(ctx context.Context, defaultPolicy *RetentionPolicy) (int, error)
+}
+
+type TrashStore interface {
+	TrashDocument(ctx context.Context, documentID string) (*Document, error)
+	RestoreDocument(ctx context.Context, documentID string) error
+	PurgeDeletedDocuments(ctx context.Context, deletedBefore time.Time) ([]string, error)
+	PurgeDocument(ctx context.Context, documentID string) error
+}
+
+type LeaderStore interface {
+	AcquireLeadership(ctx context.Context, ttl time.Duration) (bool, error)
+	ReleaseLeadership(ctx context.Context) error
+}
+
+type EventStore interface {
+	Subscribe(fn func(event Event)) func()
+}
+
+type BackupStore interface {
+	GetIDSequence(ctx context.Context) (int64, error)
+	RestoreIDSequence(ctx context.Context, value int64) error
+	ExportDocuments(ctx context.Context, fn func(document BackupDocument) error) error
+	ImportDocument(ctx context.Context, document BackupDocument) error
+}
+
+type ScrubStore interface {
+	ScrubContents(ctx context.Context, verifiedBefore time.Time, limit int) (*ScrubResult, error)
+	GetContentMismatches(ctx context.Context) ([]ContentMismatch, error)
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrDocumentDeleted is returned when a document is in the trash.
+	ErrDocumentDeleted = errors.New("document has been deleted")
+	// ErrDocumentNotDeleted is returned when a document which is not in the trash is restored.
+	ErrDocumentNotDeleted = errors.New("document is not in the trash")
+)
+
+// TrashDocument moves the document into the trash, where it is kept until it is restored or purged.
+// It returns ErrDocumentDeleted if the document is already in the trash.
+func (d *DB) TrashDocument(ctx context.Context, documentID string) (*Document, error) {
+	var document *Document
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, tx.Rebind("UPDATE documents SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL;"), time.Now(), documentID)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return err
+		} else if rows == 0 {
+			var count int
+			if err = tx.GetContext(ctx, &count, tx.Rebind("SELECT COUNT(*) FROM documents WHERE id = ?;"), documentID); err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrDocumentDeleted
+			}
+			return sql.ErrNoRows
+		}
+
+		var files []File
+		if err = tx.SelectContext(ctx, &files, tx.Rebind("SELECT f.name, f.document_id, f.document_version, f.content_hash, f.language, f.expires_at, f.order_index FROM documents d JOIN files f ON f.document_id = d.id AND f.document_version = d.latest_version WHERE d.id = ? ORDER BY f.order_index;"), documentID); err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return sql.ErrNoRows
+		}
+		if err = d.loadContents(ctx, tx, files); err != nil {
+			return err
+		}
+
+		document = &Document{
+			ID:      documentID,
+			Version: files[0].DocumentVersion,
+			Files:   files,
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to trash document: %w", err)
+	}
+	d.publish(ctx, Event{Type: EventTypeDelete, DocumentID: documentID, Version: document.Version})
+	return document, nil
+}
+
+// RestoreDocument moves the document out of the trash.
+// It returns ErrDocumentNotDeleted if the document is not in the trash.
+func (d *DB) RestoreDocument(ctx context.Context, documentID string) error {
+	res, err := d.ExecContext(ctx, d.Rebind("UPDATE documents SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL;"), documentID)
+	if err != nil {
+		return fmt.Errorf("failed to restore document: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to restore document: %w", err)
+	} else if rows == 0 {
+		var count int
+		if err = d.GetContext(ctx, &count, d.Rebind("SELECT COUNT(*) FROM documents WHERE id = ?;"), documentID); err != nil {
+			return fmt.Errorf("failed to restore document: %w", err)
+		}
+		if count > 0 {
+			return ErrDocumentNotDeleted
+		}
+		return fmt.Errorf("failed to restore document: %w", sql.ErrNoRows)
+	}
+
+	var version int64
+	if err = d.GetContext(ctx, &version, d.Rebind("SELECT latest_version FROM documents WHERE id = ?;"), documentID); err != nil {
+		return fmt.Errorf("failed to restore document: %w", err)
+	}
+	d.publish(ctx, Event{Type: EventTypeCreate, DocumentID: documentID, Version: version})
+	return nil
+}
+
+// PurgeDeletedDocuments removes all documents and their webhooks which have been moved into the trash before deletedBefore.
+// It returns the ids of the removed documents.
+func (d *DB) PurgeDeletedDocuments(ctx context.Context, deletedBefore time.Time) ([]string, error) {
+	var documentIDs []string
+	if err := d.SelectContext(ctx, &documentIDs, d.Rebind("SELECT id FROM documents WHERE deleted_at < ?;"), deletedBefore); err != nil {
+		return nil, fmt.Errorf("failed to get deleted documents: %w", err)
+	}
+	if len(documentIDs) == 0 {
+		return nil, nil
+	}
+
+	for _, documentID := range documentIDs {
+		if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+			return purgeDocument(ctx, tx, documentID)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to purge document %s: %w", documentID, err)
+		}
+	}
+
+	if err := d.deleteOrphanedContents(ctx); err != nil {
+		return nil, err
+	}
+	return documentIDs, nil
+}
+
+// PurgeDocument removes the document and its webhooks if it is in the trash.
+// It returns ErrDocumentNotDeleted if the document is not in the trash.
+func (d *DB) PurgeDocument(ctx context.Context, documentID string) error {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var deletedAt sql.NullTime
+		if err := tx.GetContext(ctx, &deletedAt, tx.Rebind("SELECT deleted_at FROM documents WHERE id = ?;"), documentID); err != nil {
+			return err
+		}
+		if !deletedAt.Valid {
+			return ErrDocumentNotDeleted
+		}
+		return purgeDocument(ctx, tx, documentID)
+	}); err != nil {
+		return fmt.Errorf("failed to purge document: %w", err)
+	}
+	return nil
+}
+
+// purgeDocument removes the document with its webhooks, which are kept while the document is in the trash.
+func purgeDocument(ctx context.Context, tx *sqlx.Tx, documentID string) error {
+	if _, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM webhooks WHERE document_id = ?;"), documentID); err != nil {
+		return err
+	}
+	return deleteDocument(ctx, tx, documentID)
+}
@@ -29,7 +29,7 @@ type WebhookUpdate struct {
 
 func (d *DB) GetWebhook(ctx context.Context, documentID string, webhookID string, secret string) (*Webhook, error) {
 	var webhook Webhook
-	err := d.GetContext(ctx, &webhook, "SELECT * FROM webhooks WHERE document_id = $1 AND id = $2 AND secret = $3", documentID, webhookID, secret)
+	err := d.GetContext(ctx, &webhook, d.Rebind("SELECT * FROM webhooks WHERE document_id = ? AND id = ? AND secret = ?"), documentID, webhookID, secret)
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +39,7 @@ func (d *DB) GetWebhook(ctx context.Context, documentID string, webhookID string
 
 func (d *DB) GetWebhooksByDocumentID(ctx context.Context, documentID string) ([]Webhook, error) {
 	var webhooks []Webhook
-	err := d.SelectContext(ctx, &webhooks, "SELECT * FROM webhooks WHERE document_id = $1", documentID)
+	err := d.SelectContext(ctx, &webhooks, d.Rebind("SELECT * FROM webhooks WHERE document_id = ?"), documentID)
 	if err != nil {
 		return nil, err
 	}
@@ -49,8 +49,13 @@ func (d *DB) GetWebhooksByDocumentID(ctx context.Context, documentID string) ([]
 
 func (d *DB) GetAndDeleteWebhooksByDocumentID(ctx context.Context, documentID string) ([]Webhook, error) {
 	var webhooks []Webhook
-	err := d.SelectContext(ctx, &webhooks, "DELETE FROM webhooks WHERE document_id = $1 RETURNING *", documentID)
-	if err != nil {
+	if err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.SelectContext(ctx, &webhooks, tx.Rebind("SELECT * FROM webhooks WHERE document_id = ?"), documentID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, tx.Rebind("DELETE FROM webhooks WHERE document_id = ?"), documentID)
+		return err
+	}); err != nil {
 		return nil, err
 	}
 
@@ -59,14 +64,22 @@ func (d *DB) GetAndDeleteWebhooksByDocumentID(ctx context.Context, documentID st
 
 func (d *DB) CreateWebhook(ctx context.Context, documentID string, url string, secret string, events []string) (*Webhook, error) {
 	webhook := Webhook{
-		ID:         d.randomString(8),
 		DocumentID: documentID,
 		URL:        url,
 		Secret:     secret,
 		Events:     strings.Join(events, ","),
 	}
 
-	if _, err := d.NamedExecContext(ctx, "INSERT INTO webhooks (id, document_id, url, secret, events) VALUES (:id, :document_id, :url, :secret, :events)", webhook); err != nil {
+	if _, err := withNewID(ctx, d.webhookIDs, d.cfg.IDs.MaxRetries, func(webhookID string) error {
+		webhook.ID = webhookID
+		if _, err := d.NamedExecContext(ctx, "INSERT INTO webhooks (id, document_id, url, secret, events) VALUES (:id, :document_id, :url, :secret, :events)", webhook); err != nil {
+			if isUniqueViolation(err) {
+				return errIDTaken
+			}
+			return err
+		}
+		return nil
+	}); err != nil {
 		return nil, fmt.Errorf("failed to insert webhook: %w", err)
 	}
 
@@ -83,25 +96,30 @@ func (d *DB) UpdateWebhook(ctx context.Context, documentID string, webhookID str
 		NewEvents:  strings.Join(newEvents, ","),
 	}
 
-	query, args, err := sqlx.Named(`UPDATE webhooks SET 
+	res, err := d.NamedExecContext(ctx, `UPDATE webhooks SET 
                     url = CASE WHEN :new_url = '' THEN url ELSE :new_url END,
                     secret = CASE WHEN :new_secret = '' THEN secret ELSE :new_secret END,
                     events = CASE WHEN :new_events = '' THEN events ELSE :new_events END
-                WHERE document_id = :document_id AND id = :id AND secret = :secret returning *`, webhookUpdate)
+                WHERE document_id = :document_id AND id = :id AND secret = :secret`, webhookUpdate)
 	if err != nil {
 		return nil, err
 	}
-
-	var webhook Webhook
-	if err = d.GetContext(ctx, webhook, query, args...); err != nil {
+	rows, err := res.RowsAffected()
+	if err != nil {
 		return nil, err
 	}
+	if rows == 0 {
+		return nil, sql.ErrNoRows
+	}
 
-	return &webhook, nil
+	if newSecret != "" {
+		secret = newSecret
+	}
+	return d.GetWebhook(ctx, documentID, webhookID, secret)
 }
 
 func (d *DB) DeleteWebhook(ctx context.Context, documentID string, webhookID string, secret string) error {
-	res, err := d.ExecContext(ctx, "DELETE FROM webhooks WHERE document_id = $1 AND id = $2 AND secret = $3", documentID, webhookID, secret)
+	res, err := d.ExecContext(ctx, d.Rebind("DELETE FROM webhooks WHERE document_id = ? AND id = ? AND secret = ?"), documentID, webhookID, secret)
 	if err != nil {
 		return err
 	}
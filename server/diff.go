@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/topi314/chroma/v2"
+
+	"github.com/topi314/gobin/v2/internal/diff"
+	"github.com/topi314/gobin/v2/internal/ezhttp"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+// diffContext is the number of unchanged lines shown around every change.
+const diffContext = 3
+
+type DiffFileStatus string
+
+const (
+	DiffFileStatusAdded    DiffFileStatus = "added"
+	DiffFileStatusRemoved  DiffFileStatus = "removed"
+	DiffFileStatusRenamed  DiffFileStatus = "renamed"
+	DiffFileStatusModified DiffFileStatus = "modified"
+)
+
+type (
+	DiffResponse struct {
+		Key       string     `json:"key"`
+		From      int64      `json:"from"`
+		To        int64      `json:"to"`
+		Files     []DiffFile `json:"files"`
+		Formatted string     `json:"formatted,omitempty"`
+	}
+
+	DiffFile struct {
+		Name     string         `json:"name"`
+		OldName  string         `json:"old_name,omitempty"`
+		Status   DiffFileStatus `json:"status"`
+		Language string         `json:"language"`
+		Hunks    []DiffHunk     `json:"hunks"`
+	}
+
+	DiffHunk struct {
+		OldStart int        `json:"old_start"`
+		OldLines int        `json:"old_lines"`
+		NewStart int        `json:"new_start"`
+		NewLines int        `json:"new_lines"`
+		Lines    []DiffLine `json:"lines"`
+	}
+
+	DiffLine struct {
+		Op      string `json:"op"`
+		Content string `json:"content"`
+	}
+)
+
+// fileDiff is the difference of a single file between two versions, a nil old or new file means it has been added or removed.
+type fileDiff struct {
+	old   *database.File
+	new   *database.File
+	hunks []diff.Hunk
+}
+
+func (d fileDiff) status() DiffFileStatus {
+	switch {
+	case d.old == nil:
+		return DiffFileStatusAdded
+	case d.new == nil:
+		return DiffFileStatusRemoved
+	case d.old.Name != d.new.Name:
+		return DiffFileStatusRenamed
+	default:
+		return DiffFileStatusModified
+	}
+}
+
+// GetDocumentDiff returns the changes between two versions of a document as json hunks or as unified diff if text/x-diff is accepted.
+// If from is omitted, the version before to is used. If to is omitted, the latest version is used.
+func (s *Server) GetDocumentDiff(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+	versionDiff, err := s.diffVersions(r.Context(), documentID, query.Get("from"), query.Get("to"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	s.touchDocument(r.Context(), documentID)
+
+	diffs := versionDiff.diffs
+	formatter, formatterName := getFormatter(r, false)
+
+	if strings.Contains(r.Header.Get("Accept"), ezhttp.MediaTypeDiff) {
+		unified := unifiedDiff(diffs)
+		if formatter == nil {
+			w.Header().Set(ezhttp.HeaderContentType, ezhttp.ContentTypeDiff)
+			_, _ = w.Write([]byte(unified))
+			return
+		}
+
+		formatted, err := s.formatDiff(unified, formatter, r)
+		if err != nil {
+			s.error(w, r, err)
+			return
+		}
+		contentType := ezhttp.ContentTypeText
+		switch formatterName {
+		case "html", "standalone-html":
+			contentType = ezhttp.ContentTypeHTML
+		case "svg":
+			contentType = ezhttp.ContentTypeSVG
+		case "json":
+			contentType = ezhttp.ContentTypeJSON
+		}
+		w.Header().Set(ezhttp.HeaderContentType, contentType)
+		_, _ = w.Write([]byte(formatted))
+		return
+	}
+
+	response := DiffResponse{
+		Key:   documentID,
+		From:  versionDiff.from,
+		To:    versionDiff.to,
+		Files: make([]DiffFile, len(diffs)),
+	}
+	for i, fileDiff := range diffs {
+		response.Files[i] = newDiffFile(fileDiff)
+	}
+	if formatter != nil {
+		if response.Formatted, err = s.formatDiff(unifiedDiff(diffs), formatter, r); err != nil {
+			s.error(w, r, err)
+			return
+		}
+	}
+	s.ok(w, r, response)
+}
+
+// versionDiff is the difference between the from and to version of a document.
+type versionDiff struct {
+	versions []database.Version
+	from     int64
+	to       int64
+	diffs    []fileDiff
+}
+
+// diffVersions resolves the from and to version strings like GetDocumentDiff and diffs the files of both versions.
+func (s *Server) diffVersions(ctx context.Context, documentID string, fromStr string, toStr string) (*versionDiff, error) {
+	documentVersions, err := s.db.GetDocumentVersionsWithInfo(ctx, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document versions: %w", err)
+	}
+	if len(documentVersions) == 0 {
+		return nil, httperr.NotFound(ErrDocumentNotFound)
+	}
+
+	versions := make([]int64, len(documentVersions))
+	for i, version := range documentVersions {
+		versions[i] = version.Version
+	}
+
+	to := versions[0]
+	if toStr != "" {
+		if to, err = parseDiffVersion(versions, toStr); err != nil {
+			return nil, err
+		}
+	}
+
+	var from int64
+	if fromStr != "" {
+		if from, err = parseDiffVersion(versions, fromStr); err != nil {
+			return nil, err
+		}
+	} else if i := slices.Index(versions, to); i+1 < len(versions) {
+		from = versions[i+1]
+	}
+
+	toFiles, err := s.db.GetDocumentVersion(ctx, documentID, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document version: %w", err)
+	}
+	var fromFiles []database.File
+	if from > 0 {
+		if fromFiles, err = s.db.GetDocumentVersion(ctx, documentID, from); err != nil {
+			return nil, fmt.Errorf("failed to get document version: %w", err)
+		}
+	}
+
+	return &versionDiff{
+		versions: documentVersions,
+		from:     from,
+		to:       to,
+		diffs:    diffFiles(fromFiles, toFiles),
+	}, nil
+}
+
+// parseDiffVersion parses the version and returns 404 like /versions/{version} if the document has no such version.
+func parseDiffVersion(versions []int64, versionStr string) (int64, error) {
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil {
+		return 0, httperr.BadRequest(ErrInvalidDocumentVersion)
+	}
+	if !slices.Contains(versions, version) {
+		return 0, httperr.NotFound(ErrDocumentNotFound)
+	}
+	return version, nil
+}
+
+func (s *Server) formatDiff(unified string, formatter chroma.Formatter, r *http.Request) (string, error) {
+	formatted, err := s.formatFile(database.File{
+		Content:  unified,
+		Language: "diff",
+	}, formatter, getStyle(r))
+	if err != nil {
+		return "", fmt.Errorf("failed to format diff: %w", err)
+	}
+	return formatted, nil
+}
+
+// diffFiles matches the files of both versions by name and diffs their contents.
+// Removed and added files with the same content are reported as renamed, unchanged files are left out.
+func diffFiles(oldFiles []database.File, newFiles []database.File) []fileDiff {
+	var (
+		diffs   []fileDiff
+		matched = make(map[string]bool, len(oldFiles))
+	)
+	for i := range newFiles {
+		newFile := &newFiles[i]
+		oldIndex := slices.IndexFunc(oldFiles, func(file database.File) bool {
+			return file.Name == newFile.Name
+		})
+		if oldIndex == -1 {
+			diffs = append(diffs, fileDiff{new: newFile})
+			continue
+		}
+
+		oldFile := &oldFiles[oldIndex]
+		matched[oldFile.Name] = true
+		if oldFile.ContentHash == newFile.ContentHash {
+			continue
+		}
+		diffs = append(diffs, fileDiff{old: oldFile, new: newFile})
+	}
+
+	for i := range oldFiles {
+		oldFile := &oldFiles[i]
+		if matched[oldFile.Name] {
+			continue
+		}
+
+		renamed := slices.IndexFunc(diffs, func(d fileDiff) bool {
+			return d.old == nil && d.new.ContentHash == oldFile.ContentHash
+		})
+		if renamed == -1 {
+			diffs = append(diffs, fileDiff{old: oldFile})
+			continue
+		}
+		diffs[renamed].old = oldFile
+	}
+
+	for i := range diffs {
+		var oldContent, newContent string
+		if diffs[i].old != nil {
+			oldContent = diffs[i].old.Content
+		}
+		if diffs[i].new != nil {
+			newContent = diffs[i].new.Content
+		}
+		diffs[i].hunks = diff.LineHunks(diff.SplitLines(oldContent), diff.SplitLines(newContent), diffContext)
+	}
+	return diffs
+}
+
+// unifiedDiff renders the file diffs in the unified format used by git.
+func unifiedDiff(diffs []fileDiff) string {
+	var buf strings.Builder
+	for _, d := range diffs {
+		oldName, newName := "/dev/null", "/dev/null"
+		if d.old != nil {
+			oldName = "a/" + d.old.Name
+		}
+		if d.new != nil {
+			newName = "b/" + d.new.Name
+		}
+
+		switch d.status() {
+		case DiffFileStatusAdded:
+			buf.WriteString("diff --git a/" + d.new.Name + " " + newName + "\nnew file mode 100644\n")
+		case DiffFileStatusRemoved:
+			buf.WriteString("diff --git " + oldName + " b/" + d.old.Name + "\ndeleted file mode 100644\n")
+		case DiffFileStatusRenamed:
+			buf.WriteString("diff --git " + oldName + " " + newName + "\nrename from " + d.old.Name + "\nrename to " + d.new.Name + "\n")
+		default:
+			buf.WriteString("diff --git " + oldName + " " + newName + "\n")
+		}
+		if len(d.hunks) == 0 {
+			continue
+		}
+
+		buf.WriteString("--- " + oldName + "\n+++ " + newName + "\n")
+		// writing to a strings.Builder never fails
+		_ = diff.WriteUnified(&buf, d.hunks)
+	}
+	return buf.String()
+}
+
+func newDiffFile(d fileDiff) DiffFile {
+	file := d.new
+	if file == nil {
+		file = d.old
+	}
+
+	diffFile := DiffFile{
+		Name:     file.Name,
+		Status:   d.status(),
+		Language: file.Language,
+		Hunks:    make([]DiffHunk, len(d.hunks)),
+	}
+	if d.status() == DiffFileStatusRenamed {
+		diffFile.OldName = d.old.Name
+	}
+	for i, hunk := range d.hunks {
+		lines := make([]DiffLine, len(hunk.Lines))
+		for j, line := range hunk.Lines {
+			lines[j] = DiffLine{
+				Op:      line.Op.String(),
+				Content: strings.TrimSuffix(line.Text, "\n"),
+			}
+		}
+		diffFile.Hunks[i] = DiffHunk{
+			OldStart: hunk.AStart,
+			OldLines: hunk.ALines,
+			NewStart: hunk.BStart,
+			NewLines: hunk.BLines,
+			Lines:    lines,
+		}
+	}
+	return diffFile
+}
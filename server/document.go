@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -40,19 +41,30 @@ var (
 	ErrDocumentTooLarge           = func(maxLength int64) error {
 		return fmt.Errorf("document too large, must be less than %d chars", maxLength)
 	}
-	ErrInvalidExpiresAt = errors.New("invalid expires_at, must be in the future")
+	ErrInvalidExpiresAt       = errors.New("invalid expires_at, must be in the future")
+	ErrSlugOnUpdate           = errors.New("slug can only be set when creating a document, add an alias instead")
+	ErrVersionMessageTooLarge = fmt.Errorf("version message too large, must be less than %d chars", maxVersionMessageLength)
+	ErrVersionAuthorTooLarge  = fmt.Errorf("version author too large, must be less than %d chars", maxVersionAuthorLength)
+)
+
+const (
+	maxVersionMessageLength = 4096
+	maxVersionAuthorLength  = 256
+	maxVersionLabelLength   = 50
 )
 
 var VersionTimeFormat = "2006-01-02 15:04:05"
 
 type (
 	DocumentResponse struct {
-		Key          string         `json:"key"`
-		Version      int64          `json:"version"`
-		VersionLabel string         `json:"version_label,omitempty"`
-		VersionTime  string         `json:"version_time,omitempty"`
-		Files        []ResponseFile `json:"files"`
-		Token        string         `json:"token,omitempty"`
+		Key            string         `json:"key"`
+		Version        int64          `json:"version"`
+		VersionLabel   string         `json:"version_label,omitempty"`
+		VersionTime    string         `json:"version_time,omitempty"`
+		VersionMessage string         `json:"version_message,omitempty"`
+		VersionAuthor  string         `json:"version_author,omitempty"`
+		Files          []ResponseFile `json:"files"`
+		Token          string         `json:"token,omitempty"`
 	}
 
 	ResponseFile struct {
@@ -63,6 +75,13 @@ type (
 		ExpiresAt *time.Time `json:"expires_at"`
 	}
 
+	RequestDocument struct {
+		Slug    string
+		Message string
+		Author  string
+		Files   []RequestFile
+	}
+
 	RequestFile struct {
 		Name      string
 		Content   string
@@ -84,7 +103,11 @@ type (
 )
 
 func (s *Server) DocumentVersions(w http.ResponseWriter, r *http.Request) {
-	documentID := chi.URLParam(r, "documentID")
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
 	withContent := r.URL.Query().Get("withContent") == "true"
 
 	versions, err := s.db.GetDocumentVersionsWithFiles(r.Context(), documentID, withContent)
@@ -97,6 +120,16 @@ func (s *Server) DocumentVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	infos, err := s.db.GetDocumentVersionsWithInfo(r.Context(), documentID)
+	if err != nil {
+		s.error(w, r, fmt.Errorf("failed to get document version infos: %w", err))
+		return
+	}
+	versionInfos := make(map[int64]database.VersionInfo, len(infos))
+	for _, info := range infos {
+		versionInfos[info.Version] = info.VersionInfo
+	}
+
 	formatter, _ := getFormatter(r, false)
 	style := getStyle(r)
 
@@ -122,9 +155,11 @@ func (s *Server) DocumentVersions(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		response = append(response, DocumentResponse{
-			Key:     documentID,
-			Version: version,
-			Files:   nil,
+			Key:            documentID,
+			Version:        version,
+			VersionMessage: versionInfos[version].Message,
+			VersionAuthor:  versionInfos[version].Author,
+			Files:          nil,
 		})
 	}
 
@@ -159,7 +194,7 @@ func (s *Server) GetPrettyDocument(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	versions, err := s.db.GetDocumentVersions(r.Context(), document.ID)
+	versions, err := s.db.GetDocumentVersionsWithInfo(r.Context(), document.ID)
 	if err != nil && errors.Is(err, sql.ErrNoRows) {
 		s.prettyError(w, r, fmt.Errorf("failed to get document versions: %w", err))
 		return
@@ -192,22 +227,6 @@ func (s *Server) GetPrettyDocument(w http.ResponseWriter, r *http.Request) {
 		totalLength += len([]rune(file.Content))
 	}
 
-	templateVersions := make([]templates.DocumentVersion, len(versions))
-	for i, v := range versions {
-		versionTime := time.UnixMilli(v)
-		versionLabel := humanize.Time(versionTime)
-		if i == 0 {
-			versionLabel += " (current)"
-		} else if i == len(versions)-1 {
-			versionLabel += " (original)"
-		}
-		templateVersions[i] = templates.DocumentVersion{
-			Version: v,
-			Label:   versionLabel,
-			Time:    versionTime.Format(VersionTimeFormat),
-		}
-	}
-
 	var (
 		previewURL string
 		previewAlt string
@@ -232,7 +251,7 @@ func (s *Server) GetPrettyDocument(w http.ResponseWriter, r *http.Request) {
 		Files:       templateFiles,
 		CurrentFile: currentFile,
 		TotalLength: totalLength,
-		Versions:    templateVersions,
+		Versions:    newTemplateVersions(versions),
 
 		Lexers: lexers.Names(false),
 		Styles: s.styles,
@@ -248,6 +267,45 @@ func (s *Server) GetPrettyDocument(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newTemplateVersions labels the versions, which are sorted from newest to oldest, for the version selects.
+func newTemplateVersions(versions []database.Version) []templates.DocumentVersion {
+	templateVersions := make([]templates.DocumentVersion, len(versions))
+	for i, v := range versions {
+		label := versionLabel(v)
+		if i == 0 {
+			label += " (current)"
+		} else if i == len(versions)-1 {
+			label += " (original)"
+		}
+		templateVersions[i] = templates.DocumentVersion{
+			Version: v.Version,
+			Label:   label,
+			Time:    time.UnixMilli(v.Version).Format(VersionTimeFormat),
+			Message: v.Message,
+			Author:  v.Author,
+		}
+	}
+	return templateVersions
+}
+
+// versionLabel labels a version with the first line of its message and its author, falling back to the humanized time.
+func versionLabel(version database.Version) string {
+	label := humanize.Time(time.UnixMilli(version.Version))
+	if version.Author != "" {
+		label = version.Author + ", " + label
+	}
+	if version.Message == "" {
+		return label
+	}
+
+	message, _, _ := strings.Cut(version.Message, "\n")
+	message = strings.TrimSpace(message)
+	if runes := []rune(message); len(runes) > maxVersionLabelLength {
+		message = strings.TrimSpace(string(runes[:maxVersionLabelLength])) + "…"
+	}
+	return message + " - " + label
+}
+
 func (s *Server) GetDocument(w http.ResponseWriter, r *http.Request) {
 	document, err := s.getDocument(r, nil)
 	if err != nil {
@@ -287,11 +345,23 @@ func (s *Server) GetDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	versions, err := s.db.GetDocumentVersionsWithInfo(r.Context(), document.ID)
+	if err != nil {
+		s.error(w, r, fmt.Errorf("failed to get document versions: %w", err))
+		return
+	}
+
 	response := DocumentResponse{
 		Key:     document.ID,
 		Version: document.Version,
 		Files:   make([]ResponseFile, len(document.Files)),
 	}
+	if i := slices.IndexFunc(versions, func(version database.Version) bool {
+		return version.Version == document.Version
+	}); i != -1 {
+		response.VersionMessage = versions[i].Message
+		response.VersionAuthor = versions[i].Author
+	}
 	for i, file := range document.Files {
 		formatted, err := s.formatFile(file, formatter, style)
 		if err != nil {
@@ -357,6 +427,9 @@ func (s *Server) GetRawDocument(w http.ResponseWriter, r *http.Request) {
 			lexer = lexers.Fallback
 		}
 		w.Header().Set(ezhttp.HeaderLanguage, lexer.Config().Name)
+		if formatter == nil {
+			w.Header().Set(ezhttp.HeaderDigest, database.Digest(file.ContentHash))
+		}
 
 		w.Header().Set(ezhttp.HeaderContentType, contentType)
 		if _, err = w.Write([]byte(formatted)); err != nil {
@@ -401,6 +474,9 @@ func (s *Server) GetRawDocument(w http.ResponseWriter, r *http.Request) {
 		}
 
 		headers.Set(ezhttp.HeaderContentType, contentType)
+		if formatter == nil {
+			headers.Set(ezhttp.HeaderDigest, database.Digest(file.ContentHash))
+		}
 
 		part, err := mpw.CreatePart(headers)
 		if err != nil {
@@ -485,10 +561,12 @@ func (s *Server) getDocument(r *http.Request, fallbackURL func(documentID string
 		}
 	}
 
-	var (
-		files []database.File
-		err   error
-	)
+	documentID, err := s.resolveDocumentID(r.Context(), documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []database.File
 	if version == 0 {
 		files, err = s.db.GetDocument(r.Context(), documentID)
 	} else {
@@ -503,10 +581,11 @@ func (s *Server) getDocument(r *http.Request, fallbackURL func(documentID string
 		}
 		return nil, fmt.Errorf("failed to get document: %w", err)
 	}
+	s.touchDocument(r.Context(), documentID)
 
 	return &database.Document{
 		ID:      documentID,
-		Version: version,
+		Version: files[0].DocumentVersion,
 		Files:   files,
 	}, nil
 }
@@ -588,6 +667,9 @@ func (s *Server) GetRawDocumentFile(w http.ResponseWriter, r *http.Request) {
 		"filename": fileName,
 	}))
 	w.Header().Set(ezhttp.HeaderContentType, contentType)
+	if formatter == nil {
+		w.Header().Set(ezhttp.HeaderDigest, database.Digest(file.ContentHash))
+	}
 
 	if _, err = w.Write([]byte(formatted)); err != nil {
 		s.error(w, r, err)
@@ -619,10 +701,12 @@ func (s *Server) getDocumentFile(r *http.Request) (*database.File, error) {
 		return nil, httperr.NotFound(ErrDocumentFileNotFound)
 	}
 
-	var (
-		file *database.File
-		err  error
-	)
+	documentID, err := s.resolveDocumentID(r.Context(), documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *database.File
 	if version == 0 {
 		file, err = s.db.GetDocumentFile(r.Context(), documentID, fileName)
 	} else {
@@ -634,19 +718,49 @@ func (s *Server) getDocumentFile(r *http.Request) (*database.File, error) {
 		}
 		return nil, fmt.Errorf("failed to get document file: %w", err)
 	}
+	s.touchDocument(r.Context(), documentID)
 
 	return file, nil
 }
 
+// resolveDocumentID resolves an alias to the id of its document and returns 410 Gone if the document is in the trash.
+func (s *Server) resolveDocumentID(ctx context.Context, id string) (string, error) {
+	documentID, err := s.db.ResolveDocumentID(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrDocumentDeleted) {
+			return "", httperr.Gone(database.ErrDocumentDeleted)
+		}
+		return "", err
+	}
+	return documentID, nil
+}
+
+// touchDocument records the view of a document if it is needed for the eviction policy.
+func (s *Server) touchDocument(ctx context.Context, documentID string) {
+	if s.cfg.Storage == nil || s.cfg.Storage.EvictionPolicy != database.EvictionPolicyLeastRecentlyViewed {
+		return
+	}
+	if err := s.db.TouchDocument(ctx, documentID); err != nil {
+		slog.ErrorContext(ctx, "failed to touch document", slog.String("document_id", documentID), tint.Err(err))
+	}
+}
+
 func (s *Server) PostDocument(w http.ResponseWriter, r *http.Request) {
-	files, err := s.parseDocumentFiles(r)
+	document, err := s.parseDocument(r)
 	if err != nil {
 		s.error(w, r, err)
 		return
 	}
 
+	if document.Slug != "" {
+		if err = s.validateSlug(document.Slug); err != nil {
+			s.error(w, r, err)
+			return
+		}
+	}
+
 	var dbFiles []database.File
-	for i, file := range files {
+	for i, file := range document.Files {
 		dbFiles = append(dbFiles, database.File{
 			Name:       file.Name,
 			Content:    file.Content,
@@ -656,8 +770,20 @@ func (s *Server) PostDocument(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	documentID, version, err := s.db.CreateDocument(r.Context(), dbFiles)
+	info := database.VersionInfo{
+		Message: document.Message,
+		Author:  document.Author,
+	}
+	documentID, version, err := s.db.CreateDocument(r.Context(), dbFiles, document.Slug, info)
 	if err != nil {
+		if errors.Is(err, database.ErrSlugTaken) {
+			s.error(w, r, httperr.Conflict(database.ErrSlugTaken))
+			return
+		}
+		if errors.Is(err, database.ErrMemoryFull) {
+			s.error(w, r, httperr.New(database.ErrMemoryFull, http.StatusInsufficientStorage))
+			return
+		}
 		s.error(w, r, fmt.Errorf("failed to create document: %w", err))
 		return
 	}
@@ -687,33 +813,44 @@ func (s *Server) PostDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	versionTime := time.UnixMilli(*version)
 	s.json(w, r, DocumentResponse{
-		Key:          *documentID,
-		Version:      *version,
-		VersionLabel: humanize.Time(versionTime) + " (original)",
-		VersionTime:  versionTime.Format(VersionTimeFormat),
-		Files:        rsFiles,
-		Token:        token,
+		Key:            *documentID,
+		Version:        *version,
+		VersionLabel:   versionLabel(database.Version{Version: *version, VersionInfo: info}) + " (original)",
+		VersionTime:    time.UnixMilli(*version).Format(VersionTimeFormat),
+		VersionMessage: info.Message,
+		VersionAuthor:  info.Author,
+		Files:          rsFiles,
+		Token:          token,
 	}, http.StatusCreated)
 
 }
 
 func (s *Server) PatchDocument(w http.ResponseWriter, r *http.Request) {
-	files, err := s.parseDocumentFiles(r)
+	document, err := s.parseDocument(r)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	if document.Slug != "" {
+		s.error(w, r, httperr.BadRequest(ErrSlugOnUpdate))
+		return
+	}
+	files := document.Files
+
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
 	if err != nil {
 		s.error(w, r, err)
 		return
 	}
 
 	claims := GetClaims(r)
-	if flags.Misses(claims.Permissions, PermissionWrite) {
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionWrite) {
 		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("write")))
 		return
 	}
 
-	documentID := chi.URLParam(r, "documentID")
-
 	var dbFiles []database.File
 	for i, file := range files {
 		dbFiles = append(dbFiles, database.File{
@@ -725,8 +862,20 @@ func (s *Server) PatchDocument(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	version, err := s.db.UpdateDocument(r.Context(), documentID, dbFiles)
+	info := database.VersionInfo{
+		Message: document.Message,
+		Author:  document.Author,
+	}
+	version, err := s.db.UpdateDocument(r.Context(), documentID, dbFiles, info)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		if errors.Is(err, database.ErrMemoryFull) {
+			s.error(w, r, httperr.New(database.ErrMemoryFull, http.StatusInsufficientStorage))
+			return
+		}
 		s.error(w, r, fmt.Errorf("failed to update document: %w", err))
 		return
 	}
@@ -765,24 +914,18 @@ func (s *Server) PatchDocument(w http.ResponseWriter, r *http.Request) {
 		Files:   webhooksFiles,
 	})
 
-	versionTime := time.UnixMilli(*version)
 	s.json(w, r, DocumentResponse{
-		Key:          documentID,
-		Version:      *version,
-		VersionLabel: humanize.Time(versionTime) + " (current)",
-		VersionTime:  versionTime.Format(VersionTimeFormat),
-		Files:        rsFiles,
+		Key:            documentID,
+		Version:        *version,
+		VersionLabel:   versionLabel(database.Version{Version: *version, VersionInfo: info}) + " (current)",
+		VersionTime:    time.UnixMilli(*version).Format(VersionTimeFormat),
+		VersionMessage: info.Message,
+		VersionAuthor:  info.Author,
+		Files:          rsFiles,
 	}, http.StatusOK)
 }
 
 func (s *Server) DeleteDocument(w http.ResponseWriter, r *http.Request) {
-	claims := GetClaims(r)
-	if flags.Misses(claims.Permissions, PermissionDelete) {
-		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("webhook")))
-		return
-	}
-
-	documentID := chi.URLParam(r, "documentID")
 	var version int64
 	if versionStr := chi.URLParam(r, "version"); versionStr != "" {
 		var err error
@@ -793,13 +936,25 @@ func (s *Server) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var (
-		document *database.Document
-		err      error
-	)
-	if version == 0 {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionDelete) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("delete")))
+		return
+	}
+
+	var document *database.Document
+	switch {
+	case version == 0 && s.cfg.Trash != nil:
+		document, err = s.db.TrashDocument(r.Context(), documentID)
+	case version == 0:
 		document, err = s.db.DeleteDocument(r.Context(), documentID)
-	} else {
+	default:
 		document, err = s.db.DeleteDocumentVersion(r.Context(), documentID, version)
 	}
 	if err != nil {
@@ -807,23 +962,13 @@ func (s *Server) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	webhooksFiles := make([]WebhookDocumentFile, len(document.Files))
-	for i, file := range document.Files {
-		webhooksFiles[i] = WebhookDocumentFile{
-			Name:      file.Name,
-			Content:   file.Content,
-			Language:  file.Language,
-			ExpiresAt: file.ExpiresAt,
-		}
-	}
-	s.ExecuteWebhooks(r.Context(), WebhookEventDelete, WebhookDocument{
-		Key:     document.ID,
-		Version: document.Version,
-		Files:   webhooksFiles,
-	})
+	webhookDocument := newWebhookDocument(*document)
+	webhookDocument.Trashed = version == 0 && s.cfg.Trash != nil
+	s.ExecuteWebhooks(r.Context(), WebhookEventDelete, webhookDocument)
 
 	if version == 0 {
 		s.ok(w, r, nil)
+		return
 	}
 
 	count, err := s.db.GetVersionCount(r.Context(), documentID)
@@ -836,6 +981,46 @@ func (s *Server) DeleteDocument(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (s *Server) PostDocumentRestore(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.db.ResolveDocumentIDIncludingTrash(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionDelete) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("delete")))
+		return
+	}
+
+	if err = s.db.RestoreDocument(r.Context(), documentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		if errors.Is(err, database.ErrDocumentNotDeleted) {
+			s.error(w, r, httperr.Conflict(database.ErrDocumentNotDeleted))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	files, err := s.db.GetDocument(r.Context(), documentID)
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+	s.ExecuteWebhooks(r.Context(), WebhookEventUpdate, newWebhookDocument(database.Document{
+		ID:      documentID,
+		Version: files[0].DocumentVersion,
+		Files:   files,
+	}))
+
+	s.ok(w, r, nil)
+}
+
 func (s *Server) PostDocumentShare(w http.ResponseWriter, r *http.Request) {
 	documentID := chi.URLParam(r, "documentID")
 
@@ -878,7 +1063,7 @@ func (s *Server) PostDocumentShare(w http.ResponseWriter, r *http.Request) {
 	s.ok(w, r, ShareResponse{Token: token})
 }
 
-func (s *Server) parseDocumentFiles(r *http.Request) ([]RequestFile, error) {
+func (s *Server) parseDocument(r *http.Request) (*RequestDocument, error) {
 	var files []RequestFile
 	contentType := r.Header.Get(ezhttp.HeaderContentType)
 	if contentType != "" {
@@ -889,6 +1074,9 @@ func (s *Server) parseDocumentFiles(r *http.Request) ([]RequestFile, error) {
 		}
 	}
 	query := r.URL.Query()
+	slug := query.Get("slug")
+	message := r.Header.Get(ezhttp.HeaderMessage)
+	author := r.Header.Get(ezhttp.HeaderAuthor)
 
 	expiresAt, err := getExpiresAt(query, r.Header)
 	if err != nil {
@@ -906,7 +1094,7 @@ func (s *Server) parseDocumentFiles(r *http.Request) ([]RequestFile, error) {
 			limitReader = gio.LimitReader(nil, s.cfg.MaxDocumentSize)
 		}
 
-		for i := 0; ; i++ {
+		for {
 			part, err := mr.NextPart()
 			if err != nil {
 				if errors.Is(err, io.EOF) {
@@ -915,7 +1103,34 @@ func (s *Server) parseDocumentFiles(r *http.Request) ([]RequestFile, error) {
 				return nil, fmt.Errorf("failed to get multipart part: %w", err)
 			}
 
-			if part.FormName() != fmt.Sprintf("file-%d", i) {
+			if strings.EqualFold(part.FormName(), "slug") && part.FileName() == "" {
+				data, err := io.ReadAll(io.LimitReader(part, 256))
+				if err != nil {
+					return nil, fmt.Errorf("failed to read slug part: %w", err)
+				}
+				slug = strings.TrimSpace(string(data))
+				continue
+			}
+
+			if strings.EqualFold(part.FormName(), "message") && part.FileName() == "" {
+				data, err := io.ReadAll(io.LimitReader(part, maxVersionMessageLength+1))
+				if err != nil {
+					return nil, fmt.Errorf("failed to read message part: %w", err)
+				}
+				message = string(data)
+				continue
+			}
+
+			if strings.EqualFold(part.FormName(), "author") && part.FileName() == "" {
+				data, err := io.ReadAll(io.LimitReader(part, maxVersionAuthorLength+1))
+				if err != nil {
+					return nil, fmt.Errorf("failed to read author part: %w", err)
+				}
+				author = string(data)
+				continue
+			}
+
+			if part.FormName() != fmt.Sprintf("file-%d", len(files)) {
 				return nil, httperr.BadRequest(ErrInvalidMultipartPartName)
 			}
 
@@ -1006,7 +1221,22 @@ func (s *Server) parseDocumentFiles(r *http.Request) ([]RequestFile, error) {
 			}
 		}
 	}
-	return files, nil
+
+	message = strings.TrimSpace(message)
+	if len(message) > maxVersionMessageLength {
+		return nil, httperr.BadRequest(ErrVersionMessageTooLarge)
+	}
+	author = strings.TrimSpace(author)
+	if len(author) > maxVersionAuthorLength {
+		return nil, httperr.BadRequest(ErrVersionAuthorTooLarge)
+	}
+
+	return &RequestDocument{
+		Slug:    slug,
+		Message: message,
+		Author:  author,
+		Files:   files,
+	}, nil
 }
 
 func getLanguage(language string, contentType string, fileName string, content string) string {
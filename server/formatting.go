@@ -33,15 +33,7 @@ func (s *Server) formatFile(file database.File, formatter chroma.Formatter, styl
 	if formatter == nil {
 		return file.Content, nil
 	}
-	lexer := lexers.Get(file.Language)
-	if s.cfg.MaxHighlightSize > 0 && len([]rune(file.Content)) > s.cfg.MaxHighlightSize {
-		lexer = lexers.Get("plaintext")
-	}
-	if lexer == nil {
-		lexer = lexers.Fallback
-	}
-
-	iterator, err := lexer.Tokenise(nil, file.Content)
+	iterator, err := s.fileLexer(file).Tokenise(nil, file.Content)
 	if err != nil {
 		return "", fmt.Errorf("tokenise: %w", err)
 	}
@@ -53,3 +45,15 @@ func (s *Server) formatFile(file database.File, formatter chroma.Formatter, styl
 
 	return buff.String(), nil
 }
+
+// fileLexer returns the lexer for the language of the file, files bigger than the max highlight size are not highlighted.
+func (s *Server) fileLexer(file database.File) chroma.Lexer {
+	lexer := lexers.Get(file.Language)
+	if s.cfg.MaxHighlightSize > 0 && len([]rune(file.Content)) > s.cfg.MaxHighlightSize {
+		lexer = lexers.Get("plaintext")
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return lexer
+}
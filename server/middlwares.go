@@ -7,6 +7,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/topi314/gobin/v2/internal/ezhttp"
 	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
 )
 
 const maxUnix = int(^int32(0)) * 1000
@@ -29,8 +31,11 @@ var (
 	}
 )
 
+// cacheKeyFunc includes when the document was last changed, so previews rendered before a change are not served anymore.
 func (s *Server) cacheKeyFunc(r *http.Request) uint64 {
-	return stampede.BytesToHash([]byte(r.Method), []byte(chi.URLParam(r, "documentID")), []byte(chi.URLParam(r, "version")), []byte(r.URL.RawQuery))
+	documentID := chi.URLParam(r, "documentID")
+	changedAt := s.previewChanges.writtenAt(documentID).UnixNano()
+	return stampede.BytesToHash([]byte(r.Method), []byte(documentID), []byte(chi.URLParam(r, "version")), []byte(r.URL.RawQuery), strconv.AppendInt(nil, changedAt, 10))
 }
 
 func cacheControl(next http.Handler) http.Handler {
@@ -76,6 +81,80 @@ func (s *Server) RateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// recentWriters remembers which documents have been changed within a window.
+type recentWriters struct {
+	window    time.Duration
+	mu        sync.Mutex
+	writes    map[string]time.Time
+	lastPrune time.Time
+}
+
+func newRecentWriters(window time.Duration) *recentWriters {
+	return &recentWriters{
+		window: window,
+		writes: make(map[string]time.Time),
+	}
+}
+
+func (w *recentWriters) record(documentID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	w.writes[documentID] = now
+	if now.Sub(w.lastPrune) < w.window {
+		return
+	}
+	w.lastPrune = now
+	for id, writtenAt := range w.writes {
+		if now.Sub(writtenAt) >= w.window {
+			delete(w.writes, id)
+		}
+	}
+}
+
+func (w *recentWriters) contains(documentID string) bool {
+	return !w.writtenAt(documentID).IsZero()
+}
+
+// writtenAt returns when the document was last changed, or the zero time if it has not been changed within the window.
+func (w *recentWriters) writtenAt(documentID string) time.Time {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	writtenAt, ok := w.writes[documentID]
+	if !ok || time.Since(writtenAt) >= w.window {
+		return time.Time{}
+	}
+	return writtenAt
+}
+
+// ReadYourWrites sends the reads of a document to the primary database for a short time after it has been changed,
+// so nobody sees stale content from a read replica which has not caught up yet.
+// Documents are keyed by the id in the url and writes also record the token subject, which is the id of the aliased document.
+// New documents don't need to be recorded, reading them falls back to the primary until they have been replicated.
+func (s *Server) ReadYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		documentID := chi.URLParam(r, "documentID")
+		if i := strings.Index(documentID, "."); i > 0 {
+			documentID = documentID[:i]
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if s.recentWriters.contains(documentID) {
+				r = r.WithContext(database.WithPrimary(r.Context()))
+			}
+			next.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+			s.recentWriters.record(documentID)
+			if subject := GetClaims(r).Subject; subject != "" && subject != documentID {
+				s.recentWriters.record(subject)
+			}
+		}
+	})
+}
+
 func (s *Server) JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := r.Header.Get(ezhttp.HeaderAuthorization)
@@ -84,7 +163,8 @@ func (s *Server) JWTMiddleware(next http.Handler) http.Handler {
 		}
 
 		var claims Claims
-		if tokenString == "" {
+		// the admin token is checked by AdminAuth and grants no document permissions
+		if tokenString == "" || s.isAdminToken(tokenString) {
 			documentID := chi.URLParam(r, "documentID")
 			claims = EmptyClaims(documentID)
 		} else {
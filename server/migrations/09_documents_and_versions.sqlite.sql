@@ -0,0 +1,30 @@
+--- v2.2.0 - sqlite
+
+CREATE TABLE documents
+(
+    id             VARCHAR   NOT NULL,
+    latest_version BIGINT    NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    metadata       TEXT      NOT NULL DEFAULT '{}',
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE versions
+(
+    document_id VARCHAR NOT NULL,
+    version     BIGINT  NOT NULL,
+    PRIMARY KEY (document_id, version)
+);
+
+INSERT INTO versions (document_id, version)
+SELECT DISTINCT document_id, document_version
+FROM files;
+
+INSERT INTO documents (id, latest_version, created_at)
+SELECT document_id, MAX(version), datetime(MIN(version) / 1000, 'unixepoch')
+FROM versions
+GROUP BY document_id;
+
+CREATE INDEX versions_version_idx ON versions (version);
+CREATE INDEX files_document_id_document_version_idx ON files (document_id, document_version);
+CREATE INDEX files_expires_at_idx ON files (expires_at);
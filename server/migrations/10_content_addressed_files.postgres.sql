@@ -0,0 +1,27 @@
+--- v2.3.0 - postgres
+
+CREATE TABLE contents
+(
+    hash       VARCHAR   NOT NULL,
+    content    BYTEA     NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (hash)
+);
+
+ALTER TABLE files
+    ADD COLUMN content_hash VARCHAR;
+
+UPDATE files
+SET content_hash = encode(sha256(convert_to(content, 'UTF8')), 'hex');
+
+INSERT INTO contents (hash, content, updated_at)
+SELECT DISTINCT content_hash, convert_to(content, 'UTF8'), CURRENT_TIMESTAMP
+FROM files;
+
+ALTER TABLE files
+    ALTER COLUMN content_hash SET NOT NULL;
+
+ALTER TABLE files
+    DROP COLUMN content;
+
+CREATE INDEX files_content_hash_idx ON files (content_hash);
@@ -0,0 +1,24 @@
+--- v2.3.0 - sqlite
+
+CREATE TABLE contents
+(
+    hash       VARCHAR   NOT NULL,
+    content    BLOB      NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (hash)
+);
+
+ALTER TABLE files
+    ADD COLUMN content_hash VARCHAR NOT NULL DEFAULT '';
+
+UPDATE files
+SET content_hash = sha256(content);
+
+INSERT INTO contents (hash, content, updated_at)
+SELECT DISTINCT content_hash, CAST(content AS BLOB), CURRENT_TIMESTAMP
+FROM files;
+
+ALTER TABLE files
+    DROP COLUMN content;
+
+CREATE INDEX files_content_hash_idx ON files (content_hash);
@@ -0,0 +1,9 @@
+--- v2.4.0
+
+ALTER TABLE contents
+    ADD COLUMN base_hash VARCHAR;
+
+ALTER TABLE contents
+    ADD COLUMN chain_length BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX contents_base_hash_idx ON contents (base_hash);
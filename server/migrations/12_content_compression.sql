@@ -0,0 +1,4 @@
+--- v2.5.0
+
+ALTER TABLE contents
+    ADD COLUMN compression VARCHAR NOT NULL DEFAULT '';
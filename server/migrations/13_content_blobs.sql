@@ -0,0 +1,4 @@
+--- v2.6.0
+
+ALTER TABLE contents
+    ADD COLUMN blob_key VARCHAR;
@@ -0,0 +1,7 @@
+--- v2.7.0 - sqlite
+
+ALTER TABLE contents
+    ADD COLUMN key_id VARCHAR;
+
+ALTER TABLE contents
+    ADD COLUMN data_key BLOB;
@@ -0,0 +1,11 @@
+--- v2.8.0
+
+CREATE TABLE id_sequences
+(
+    name  VARCHAR NOT NULL,
+    value BIGINT  NOT NULL,
+    PRIMARY KEY (name)
+);
+
+INSERT INTO id_sequences (name, value)
+VALUES ('documents', 0);
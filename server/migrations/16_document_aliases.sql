@@ -0,0 +1,11 @@
+--- v2.8.0
+
+CREATE TABLE document_aliases
+(
+    alias       VARCHAR   NOT NULL,
+    document_id VARCHAR   NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (alias)
+);
+
+CREATE INDEX document_aliases_document_id_idx ON document_aliases (document_id);
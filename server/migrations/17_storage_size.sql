@@ -0,0 +1,11 @@
+--- v2.9.0
+
+ALTER TABLE contents
+    ADD COLUMN size BIGINT NOT NULL DEFAULT 0;
+
+-- contents stored in blobs have an empty content column, their size is backfilled from the blob store later
+UPDATE contents
+SET size = LENGTH(content);
+
+ALTER TABLE documents
+    ADD COLUMN viewed_at TIMESTAMP;
@@ -0,0 +1,10 @@
+--- v2.9.0
+
+-- leases are used to elect a single instance for jobs like the cleanup
+CREATE TABLE leases
+(
+    name       VARCHAR   NOT NULL,
+    holder     VARCHAR   NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (name)
+);
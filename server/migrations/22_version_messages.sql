@@ -0,0 +1,8 @@
+--- v2.9.0
+
+-- message and author are optional labels given when a version is created
+ALTER TABLE versions
+    ADD COLUMN message VARCHAR;
+
+ALTER TABLE versions
+    ADD COLUMN author VARCHAR;
@@ -0,0 +1,7 @@
+--- v2.9.0
+
+-- contents stored in blobs got no size from their empty content column, -1 marks them to be backfilled from the blob store
+UPDATE contents
+SET size = -1
+WHERE blob_key IS NOT NULL
+  AND size = 0;
@@ -0,0 +1,61 @@
+--- v2.6.0 - mysql
+-- MySQL support was added with schema version 13, so the schema is created as a whole.
+-- Later migrations have to be added with the same version as their postgres and sqlite counterparts.
+
+CREATE TABLE documents
+(
+    id             VARCHAR(255) NOT NULL,
+    latest_version BIGINT       NOT NULL,
+    created_at     DATETIME(6)  NOT NULL,
+    metadata       TEXT         NOT NULL DEFAULT ('{}'),
+    PRIMARY KEY (id)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE versions
+(
+    document_id VARCHAR(255) NOT NULL,
+    version     BIGINT       NOT NULL,
+    PRIMARY KEY (document_id, version)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE INDEX versions_version_idx ON versions (version);
+
+CREATE TABLE contents
+(
+    hash         VARCHAR(64)  NOT NULL,
+    content      LONGBLOB     NOT NULL,
+    base_hash    VARCHAR(64),
+    chain_length BIGINT       NOT NULL DEFAULT 0,
+    compression  VARCHAR(16)  NOT NULL DEFAULT '',
+    blob_key     VARCHAR(255),
+    updated_at   DATETIME(6)  NOT NULL,
+    PRIMARY KEY (hash)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE INDEX contents_base_hash_idx ON contents (base_hash);
+
+CREATE TABLE files
+(
+    name             VARCHAR(255) NOT NULL,
+    document_id      VARCHAR(255) NOT NULL,
+    document_version BIGINT       NOT NULL,
+    content_hash     VARCHAR(64)  NOT NULL DEFAULT '',
+    language         VARCHAR(255) NOT NULL,
+    expires_at       DATETIME(6),
+    order_index      BIGINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (name, document_id, document_version)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE INDEX files_document_id_document_version_idx ON files (document_id, document_version);
+CREATE INDEX files_expires_at_idx ON files (expires_at);
+CREATE INDEX files_content_hash_idx ON files (content_hash);
+
+CREATE TABLE webhooks
+(
+    id          VARCHAR(255)  NOT NULL,
+    document_id VARCHAR(255)  NOT NULL,
+    url         VARCHAR(2048) NOT NULL,
+    secret      VARCHAR(255)  NOT NULL,
+    events      VARCHAR(255)  NOT NULL,
+    PRIMARY KEY (id)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
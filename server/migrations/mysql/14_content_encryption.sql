@@ -0,0 +1,7 @@
+--- v2.7.0 - mysql
+
+ALTER TABLE contents
+    ADD COLUMN key_id VARCHAR(255);
+
+ALTER TABLE contents
+    ADD COLUMN data_key VARBINARY(255);
@@ -0,0 +1,11 @@
+--- v2.8.0 - mysql
+
+CREATE TABLE document_aliases
+(
+    alias       VARCHAR(255) NOT NULL,
+    document_id VARCHAR(255) NOT NULL,
+    created_at  DATETIME(6)  NOT NULL,
+    PRIMARY KEY (alias)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE INDEX document_aliases_document_id_idx ON document_aliases (document_id);
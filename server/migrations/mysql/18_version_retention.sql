@@ -0,0 +1,9 @@
+--- v2.9.0 - mysql
+
+-- a document uses the server retention policy if no own policy is set, durations are stored in nanoseconds
+ALTER TABLE documents
+    ADD COLUMN retention_keep_last INTEGER;
+ALTER TABLE documents
+    ADD COLUMN retention_max_age BIGINT;
+ALTER TABLE documents
+    ADD COLUMN retention_thin_after BIGINT;
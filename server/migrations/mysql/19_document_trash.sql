@@ -0,0 +1,5 @@
+--- v2.9.0 - mysql
+
+-- documents with deleted_at set are in the trash until they are purged by the cleanup
+ALTER TABLE documents
+    ADD COLUMN deleted_at DATETIME(6);
@@ -0,0 +1,10 @@
+--- v2.9.0 - mysql
+
+-- leases are used to elect a single instance for jobs like the cleanup
+CREATE TABLE leases
+(
+    name       VARCHAR(64)  NOT NULL,
+    holder     VARCHAR(255) NOT NULL,
+    expires_at DATETIME(6)  NOT NULL,
+    PRIMARY KEY (name)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
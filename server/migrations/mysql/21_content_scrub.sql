@@ -0,0 +1,8 @@
+--- v2.9.0 - mysql
+
+-- verified_at and verify_error are set by the scrub job whenever it re-verifies a stored content against its hash
+ALTER TABLE contents
+    ADD COLUMN verified_at DATETIME(6);
+
+ALTER TABLE contents
+    ADD COLUMN verify_error TEXT;
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/topi314/gobin/v2/internal/flags"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/internal/timex"
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+type (
+	RetentionRequest struct {
+		KeepLast  int            `json:"keep_last"`
+		MaxAge    timex.Duration `json:"max_age"`
+		ThinAfter timex.Duration `json:"thin_after"`
+	}
+
+	RetentionResponse struct {
+		Key       string         `json:"key"`
+		KeepLast  int            `json:"keep_last"`
+		MaxAge    timex.Duration `json:"max_age"`
+		ThinAfter timex.Duration `json:"thin_after"`
+		// Default is true if the document uses the server retention policy.
+		Default bool `json:"default"`
+	}
+)
+
+func (s *Server) GetDocumentRetention(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	policy, err := s.db.GetDocumentRetention(r.Context(), documentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	s.ok(w, r, s.newRetentionResponse(documentID, policy))
+}
+
+func (s *Server) PutDocumentRetention(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	var retentionRequest RetentionRequest
+	if err = json.NewDecoder(r.Body).Decode(&retentionRequest); err != nil {
+		s.error(w, r, httperr.BadRequest(err))
+		return
+	}
+
+	policy := database.RetentionPolicy{
+		KeepLast:  retentionRequest.KeepLast,
+		MaxAge:    retentionRequest.MaxAge,
+		ThinAfter: retentionRequest.ThinAfter,
+	}
+	if err = policy.Validate(); err != nil {
+		s.error(w, r, httperr.BadRequest(err))
+		return
+	}
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionWrite) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("write")))
+		return
+	}
+
+	if err = s.db.SetDocumentRetention(r.Context(), documentID, &policy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	s.ok(w, r, s.newRetentionResponse(documentID, &policy))
+}
+
+func (s *Server) DeleteDocumentRetention(w http.ResponseWriter, r *http.Request) {
+	documentID, err := s.resolveDocumentID(r.Context(), chi.URLParam(r, "documentID"))
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	claims := GetClaims(r)
+	if claims.Subject != documentID || flags.Misses(claims.Permissions, PermissionWrite) {
+		s.error(w, r, httperr.Forbidden(ErrPermissionDenied("write")))
+		return
+	}
+
+	if err = s.db.SetDocumentRetention(r.Context(), documentID, nil); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.error(w, r, httperr.NotFound(ErrDocumentNotFound))
+			return
+		}
+		s.error(w, r, err)
+		return
+	}
+
+	s.ok(w, r, s.newRetentionResponse(documentID, nil))
+}
+
+// newRetentionResponse returns the effective retention policy of the document, which is the server policy if the document has none.
+func (s *Server) newRetentionResponse(documentID string, policy *database.RetentionPolicy) RetentionResponse {
+	response := RetentionResponse{
+		Key:     documentID,
+		Default: policy == nil,
+	}
+	if policy == nil {
+		policy = s.cfg.Retention
+	}
+	if policy != nil {
+		response.KeepLast = policy.KeepLast
+		response.MaxAge = policy.MaxAge
+		response.ThinAfter = policy.ThinAfter
+	}
+	return response
+}
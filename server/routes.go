@@ -88,19 +88,32 @@ func (s *Server) Routes() http.Handler {
 
 	r.Get("/version", s.GetVersion)
 
+	if s.cfg.AdminToken != "" {
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.AdminAuth)
+			r.Get("/scrub", s.GetScrub)
+		})
+	}
+
 	r.Route("/documents", func(r chi.Router) {
 		r.Post("/", s.PostDocument)
 
 		filesHandler := func(r chi.Router) {
 			r.Route("/files/{fileName}", func(r chi.Router) {
 				r.Get("/", s.GetDocumentFile)
+				r.Get("/blame", s.GetDocumentFileBlame)
 			})
 		}
 		r.Route("/{documentID}", func(r chi.Router) {
+			if s.recentWriters != nil {
+				r.Use(s.ReadYourWrites)
+			}
 			r.Get("/", s.GetDocument)
 			r.Patch("/", s.PatchDocument)
 			r.Delete("/", s.DeleteDocument)
 			r.Post("/share", s.PostDocumentShare)
+			r.Post("/restore", s.PostDocumentRestore)
+			r.Get("/diff", s.GetDocumentDiff)
 
 			r.Route("/versions", func(r chi.Router) {
 				r.Get("/", s.DocumentVersions)
@@ -110,6 +123,18 @@ func (s *Server) Routes() http.Handler {
 				})
 			})
 
+			r.Route("/aliases", func(r chi.Router) {
+				r.Get("/", s.GetDocumentAliases)
+				r.Post("/", s.PostDocumentAlias)
+				r.Delete("/{alias}", s.DeleteDocumentAlias)
+			})
+
+			r.Route("/retention", func(r chi.Router) {
+				r.Get("/", s.GetDocumentRetention)
+				r.Put("/", s.PutDocumentRetention)
+				r.Delete("/", s.DeleteDocumentRetention)
+			})
+
 			r.Route("/webhooks", func(r chi.Router) {
 				r.Post("/", s.PostDocumentWebhook)
 				r.Route("/{webhookID}", func(r chi.Router) {
@@ -129,6 +154,9 @@ func (s *Server) Routes() http.Handler {
 		})
 	}
 	r.Route("/raw/{documentID}", func(r chi.Router) {
+		if s.recentWriters != nil {
+			r.Use(s.ReadYourWrites)
+		}
 		r.Get("/", s.GetRawDocument)
 		r.Route("/versions/{version}", func(r chi.Router) {
 			r.Get("/", s.GetRawDocument)
@@ -138,8 +166,16 @@ func (s *Server) Routes() http.Handler {
 	})
 
 	r.Route("/{documentID}", func(r chi.Router) {
+		if s.recentWriters != nil {
+			r.Use(s.ReadYourWrites)
+		}
 		r.Get("/", s.GetPrettyDocument)
 		previewHandler(r)
+		r.Route("/compare", func(r chi.Router) {
+			r.Get("/", s.GetPrettyCompare)
+			r.Get("/{versions}", s.GetPrettyCompare)
+		})
+		r.Get("/blame/{fileName}", s.GetPrettyBlame)
 		r.Route("/{version}", func(r chi.Router) {
 			r.Get("/", s.GetPrettyDocument)
 			previewHandler(r)
@@ -169,7 +205,7 @@ func (s *Server) prettyError(w http.ResponseWriter, r *http.Request, err error)
 	if errors.As(err, &httpErr) {
 		status = httpErr.Status
 
-		if httpErr.Location != "" {
+		if httpErr != nil && httpErr.Location != "" {
 			http.Redirect(w, r, httpErr.Location, status)
 			return
 		}
@@ -197,7 +233,7 @@ func (s *Server) error(w http.ResponseWriter, r *http.Request, err error) {
 		status = httpErr.Status
 	}
 
-	if httpErr.Location != "" {
+	if httpErr != nil && httpErr.Location != "" {
 		http.Redirect(w, r, httpErr.Location, status)
 		return
 	}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/topi314/tint"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/topi314/gobin/v2/internal/ezhttp"
+	"github.com/topi314/gobin/v2/internal/httperr"
+	"github.com/topi314/gobin/v2/server/database"
+)
+
+var ErrInvalidAdminToken = errors.New("invalid admin token")
+
+type ContentMismatchResponse struct {
+	DocumentID string    `json:"document_id"`
+	Version    int64     `json:"version"`
+	FileName   string    `json:"file_name"`
+	Digest     string    `json:"digest"`
+	Error      string    `json:"error"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+type ScrubResponse struct {
+	Mismatches []ContentMismatchResponse `json:"mismatches"`
+}
+
+type scrubMetrics struct {
+	checked    metric.Int64Counter
+	mismatches metric.Int64Counter
+}
+
+func newScrubMetrics(meter metric.Meter) (*scrubMetrics, error) {
+	checked, err := meter.Int64Counter("gobin.scrub.checked",
+		metric.WithDescription("Number of stored contents verified by the scrub job"),
+		metric.WithUnit("{content}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	mismatches, err := meter.Int64Counter("gobin.scrub.mismatches",
+		metric.WithDescription("Number of files whose content failed verification by the scrub job"),
+		metric.WithUnit("{file}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &scrubMetrics{
+		checked:    checked,
+		mismatches: mismatches,
+	}, nil
+}
+
+// scrubContents re-verifies a batch of stored contents against their hashes and reports every mismatch.
+func (s *Server) scrubContents(ctx context.Context) {
+	ctx, span := s.tracer.Start(ctx, "scrubContents")
+	defer span.End()
+
+	batchSize := s.cfg.Scrub.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := time.Duration(s.cfg.Scrub.Interval)
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	result, err := s.db.ScrubContents(ctx, time.Now().Add(-interval), batchSize)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		span.SetStatus(codes.Error, "failed to scrub contents")
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to scrub contents", tint.Err(err))
+		return
+	}
+
+	if s.scrubMetrics != nil {
+		s.scrubMetrics.checked.Add(ctx, int64(result.Checked))
+		s.scrubMetrics.mismatches.Add(ctx, int64(len(result.Mismatches)))
+	}
+	for _, mismatch := range result.Mismatches {
+		slog.ErrorContext(ctx, "content failed verification",
+			slog.String("document_id", mismatch.DocumentID),
+			slog.Int64("version", mismatch.DocumentVersion),
+			slog.String("file_name", mismatch.FileName),
+			slog.String("content_hash", mismatch.ContentHash),
+			slog.String("error", mismatch.Error),
+		)
+	}
+	if result.Checked > 0 {
+		slog.DebugContext(ctx, "scrubbed contents", slog.Int("checked", result.Checked), slog.Int("mismatches", len(result.Mismatches)))
+	}
+}
+
+func (s *Server) isAdminToken(token string) bool {
+	return s.cfg.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) == 1
+}
+
+// AdminAuth only lets requests through which carry the configured admin token as bearer token.
+func (s *Server) AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(ezhttp.HeaderAuthorization)
+		if len(token) > 7 && strings.ToUpper(token[0:6]) == "BEARER" {
+			token = token[7:]
+		}
+		if !s.isAdminToken(token) {
+			s.error(w, r, httperr.Unauthorized(ErrInvalidAdminToken))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) GetScrub(w http.ResponseWriter, r *http.Request) {
+	mismatches, err := s.db.GetContentMismatches(r.Context())
+	if err != nil {
+		s.error(w, r, err)
+		return
+	}
+
+	response := ScrubResponse{
+		Mismatches: make([]ContentMismatchResponse, 0, len(mismatches)),
+	}
+	for _, mismatch := range mismatches {
+		response.Mismatches = append(response.Mismatches, ContentMismatchResponse{
+			DocumentID: mismatch.DocumentID,
+			Version:    mismatch.DocumentVersion,
+			FileName:   mismatch.FileName,
+			Digest:     database.Digest(mismatch.ContentHash),
+			Error:      mismatch.Error,
+			VerifiedAt: mismatch.VerifiedAt,
+		})
+	}
+	s.ok(w, r, response)
+}
@@ -2,10 +2,12 @@ package server
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptrace"
+	"regexp"
 	"sync"
 	"time"
 
@@ -26,7 +28,7 @@ import (
 	"github.com/topi314/gobin/v2/server/templates"
 )
 
-func NewServer(version string, debug bool, cfg Config, db *database.DB, signer jose.Signer, tracer trace.Tracer, meter metric.Meter, assets http.FileSystem, htmlFormatter *html.Formatter, standaloneHTMLFormatter *html.Formatter) *Server {
+func NewServer(version string, debug bool, cfg Config, db database.Store, signer jose.Signer, tracer trace.Tracer, meter metric.Meter, assets http.FileSystem, htmlFormatter *html.Formatter, standaloneHTMLFormatter *html.Formatter) *Server {
 	var allStyles []templates.Style
 	for _, name := range styles.Names() {
 		allStyles = append(allStyles, templates.Style{
@@ -48,6 +50,9 @@ func NewServer(version string, debug bool, cfg Config, db *database.DB, signer j
 		}
 	}
 
+	// the pattern is validated when loading the config
+	slugPattern, _ := newSlugPattern(cfg.Slugs)
+
 	s := &Server{
 		version:                 version,
 		debug:                   debug,
@@ -61,6 +66,24 @@ func NewServer(version string, debug bool, cfg Config, db *database.DB, signer j
 		styles:                  allStyles,
 		htmlFormatter:           htmlFormatter,
 		standaloneHTMLFormatter: standaloneHTMLFormatter,
+		slugPattern:             slugPattern,
+	}
+
+	if cfg.Scrub != nil {
+		scrubMetrics, err := newScrubMetrics(meter)
+		if err != nil {
+			slog.Error("Error while creating scrub metrics", tint.Err(err))
+		}
+		s.scrubMetrics = scrubMetrics
+	}
+
+	if cfg.Preview != nil && cfg.Preview.CacheSize > 0 && cfg.Preview.CacheTTL > 0 {
+		// cached previews are at most CacheTTL old, so older changes don't have to be remembered
+		s.previewChanges = newRecentWriters(time.Duration(cfg.Preview.CacheTTL))
+	}
+
+	if cfg.Database.ReadReplicas != nil && cfg.Database.ReadReplicas.ReadYourWritesWindow > 0 {
+		s.recentWriters = newRecentWriters(time.Duration(cfg.Database.ReadReplicas.ReadYourWritesWindow))
 	}
 
 	s.server = &http.Server{
@@ -85,7 +108,7 @@ type Server struct {
 	version                 string
 	debug                   bool
 	cfg                     Config
-	db                      *database.DB
+	db                      database.Store
 	server                  *http.Server
 	client                  *http.Client
 	signer                  jose.Signer
@@ -96,15 +119,26 @@ type Server struct {
 	standaloneHTMLFormatter *html.Formatter
 	styles                  []templates.Style
 	rateLimitHandler        func(http.Handler) http.Handler
+	slugPattern             *regexp.Regexp
 	webhookWaitGroup        sync.WaitGroup
 	cleanupCancel           context.CancelFunc
+	cleanupWaitGroup        sync.WaitGroup
+	unsubscribeEvents       func()
+	recentWriters           *recentWriters
+	previewChanges          *recentWriters
+	scrubMetrics            *scrubMetrics
 }
 
 func (s *Server) Start() {
 	cleanupContext, cancel := context.WithCancel(context.Background())
 	s.cleanupCancel = cancel
+	s.unsubscribeEvents = s.db.Subscribe(s.onDocumentEvent)
 
-	go s.cleanup(cleanupContext, time.Duration(s.cfg.Database.CleanupInterval), time.Duration(s.cfg.Database.ExpireAfter))
+	s.cleanupWaitGroup.Add(1)
+	go func() {
+		defer s.cleanupWaitGroup.Done()
+		s.cleanup(cleanupContext, time.Duration(s.cfg.Database.CleanupInterval), time.Duration(s.cfg.Database.ExpireAfter))
+	}()
 	if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("Error while listening", tint.Err(err))
 	}
@@ -112,6 +146,8 @@ func (s *Server) Start() {
 
 func (s *Server) Close() {
 	s.cleanupCancel()
+	s.cleanupWaitGroup.Wait()
+	s.unsubscribeEvents()
 
 	if err := s.server.Close(); err != nil {
 		slog.Error("Error while closing server", tint.Err(err))
@@ -124,6 +160,25 @@ func (s *Server) Close() {
 	}
 }
 
+// onDocumentEvent is called for every document change made by any instance sharing the database.
+// Changes made by other instances have to invalidate cached previews and send reads to the primary as well.
+func (s *Server) onDocumentEvent(event database.Event) {
+	slog.Debug("Received document event",
+		slog.String("type", string(event.Type)),
+		slog.String("document_id", event.DocumentID),
+		slog.Int64("version", event.Version),
+	)
+	if s.previewChanges != nil {
+		s.previewChanges.record(event.DocumentID)
+	}
+	if s.recentWriters != nil {
+		s.recentWriters.record(event.DocumentID)
+	}
+	if event.Evicted != nil {
+		s.ExecuteWebhooks(context.Background(), WebhookEventDelete, newWebhookDocument(*event.Evicted))
+	}
+}
+
 func (s *Server) cleanup(ctx context.Context, cleanUpInterval time.Duration, expireAfter time.Duration) {
 	if cleanUpInterval <= 0 {
 		cleanUpInterval = 10 * time.Minute
@@ -142,16 +197,56 @@ func (s *Server) cleanup(ctx context.Context, cleanUpInterval time.Duration, exp
 		slog.Debug("document cleanup stopped")
 	}()
 
+	var leader bool
+	if s.cfg.Database.LeaderElection != nil {
+		defer func() {
+			if !leader {
+				return
+			}
+			releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := s.db.ReleaseLeadership(releaseCtx); err != nil {
+				slog.Error("failed to release cleanup leadership", tint.Err(err))
+			}
+		}()
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if s.cfg.Database.LeaderElection != nil {
+				leader = s.acquireCleanupLeadership(ctx, leader, cleanUpInterval)
+				if !leader {
+					continue
+				}
+			}
 			s.doCleanup(ctx, expireAfter)
 		}
 	}
 }
 
+// acquireCleanupLeadership returns whether this instance should run the cleanup, so only one of multiple instances sharing a database does.
+func (s *Server) acquireCleanupLeadership(ctx context.Context, leader bool, cleanUpInterval time.Duration) bool {
+	ttl := time.Duration(s.cfg.Database.LeaderElection.LeaseDuration)
+	if ttl <= 0 {
+		ttl = 3 * cleanUpInterval
+	}
+
+	acquired, err := s.db.AcquireLeadership(ctx, ttl)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to acquire cleanup leadership", tint.Err(err))
+		return false
+	}
+	if acquired && !leader {
+		slog.InfoContext(ctx, "acquired cleanup leadership")
+	} else if !acquired && leader {
+		slog.InfoContext(ctx, "lost cleanup leadership")
+	}
+	return acquired
+}
+
 func (s *Server) doCleanup(ctx context.Context, expireAfter time.Duration) {
 	ctx, span := s.tracer.Start(ctx, "doCleanup")
 	defer span.End()
@@ -165,25 +260,132 @@ func (s *Server) doCleanup(ctx context.Context, expireAfter time.Duration) {
 		slog.ErrorContext(ctx, "failed to delete expired documents", tint.Err(err))
 	}
 
-	var wg sync.WaitGroup
-	for i := range documents {
-		wg.Add(1)
-		go func(ctx context.Context, document database.Document) {
-			webhooksFiles := make([]WebhookDocumentFile, len(document.Files))
-			for i, file := range document.Files {
-				webhooksFiles[i] = WebhookDocumentFile{
-					Name:      file.Name,
-					Content:   file.Content,
-					Language:  file.Language,
-					ExpiresAt: file.ExpiresAt,
+	for _, document := range documents {
+		// expired files are removed per version, so the document is only gone if no version is left
+		event := WebhookEventUpdate
+		if count, err := s.db.GetVersionCount(ctx, document.ID); err == nil && count == 0 {
+			event = WebhookEventDelete
+		}
+		s.ExecuteWebhooks(ctx, event, newWebhookDocument(document))
+	}
+
+	if s.cfg.Trash != nil {
+		documentIDs, err := s.db.PurgeDeletedDocuments(ctx, time.Now().Add(-time.Duration(s.cfg.Trash.GracePeriod)))
+		if err != nil {
+			span.SetStatus(codes.Error, "failed to purge deleted documents")
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "failed to purge deleted documents", tint.Err(err))
+		} else if len(documentIDs) > 0 {
+			slog.InfoContext(ctx, "purged deleted documents", slog.Int("count", len(documentIDs)))
+		}
+	}
+
+	versions, err := s.db.ApplyRetentionPolicies(ctx, s.cfg.Retention)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to apply retention policies")
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to apply retention policies", tint.Err(err))
+	} else if versions > 0 {
+		slog.InfoContext(ctx, "removed versions by retention policies", slog.Int("count", versions))
+	}
+
+	if s.cfg.Storage != nil && s.cfg.Storage.MaxSize > 0 {
+		s.evictDocuments(ctx)
+	}
+
+	if s.cfg.Scrub != nil {
+		s.scrubContents(ctx)
+	}
+}
+
+// evictDocuments deletes documents in the order of the configured eviction policy until the storage size is below the max size.
+func (s *Server) evictDocuments(ctx context.Context) {
+	ctx, span := s.tracer.Start(ctx, "evictDocuments")
+	defer span.End()
+
+	batchSize := s.cfg.Storage.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	// contents stored in blobs before sizes were tracked have to be measured first
+	for {
+		backfilled, err := s.db.BackfillContentSizes(ctx, batchSize)
+		if err != nil {
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "failed to backfill content sizes", tint.Err(err))
+			break
+		}
+		if backfilled < batchSize {
+			break
+		}
+	}
+
+	size, err := s.db.GetStorageSize(ctx)
+	if err != nil {
+		span.SetStatus(codes.Error, "failed to get storage size")
+		span.RecordError(err)
+		slog.ErrorContext(ctx, "failed to get storage size", tint.Err(err))
+		return
+	}
+	if size <= s.cfg.Storage.MaxSize {
+		return
+	}
+
+	var evicted int
+	for size > s.cfg.Storage.MaxSize {
+		candidates, err := s.db.GetEvictionCandidates(ctx, s.cfg.Storage.EvictionPolicy, batchSize)
+		if err != nil {
+			span.SetStatus(codes.Error, "failed to get eviction candidates")
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "failed to get eviction candidates", tint.Err(err))
+			break
+		}
+
+		var deleted int
+		for _, candidate := range candidates {
+			if size <= s.cfg.Storage.MaxSize {
+				break
+			}
+			if err = s.evictDocument(ctx, candidate); err != nil {
+				if errors.Is(err, sql.ErrNoRows) || errors.Is(err, database.ErrDocumentNotDeleted) {
+					continue
 				}
+				span.SetStatus(codes.Error, "failed to evict document")
+				span.RecordError(err)
+				slog.ErrorContext(ctx, "failed to evict document", slog.String("document_id", candidate.ID), tint.Err(err))
+				return
 			}
-			s.ExecuteWebhooks(ctx, WebhookEventUpdate, WebhookDocument{
-				Key:     document.ID,
-				Version: document.Version,
-				Files:   webhooksFiles,
-			})
-		}(ctx, documents[i])
-	}
-	wg.Wait()
+			deleted++
+			// contents shared with other documents are not freed, so this can be too optimistic until the size is measured after the batch
+			size -= candidate.Size
+		}
+		// stop if no document could be deleted, otherwise the same candidates would be returned forever
+		if deleted == 0 {
+			break
+		}
+		evicted += deleted
+
+		if size, err = s.db.GetStorageSize(ctx); err != nil {
+			span.SetStatus(codes.Error, "failed to get storage size")
+			span.RecordError(err)
+			slog.ErrorContext(ctx, "failed to get storage size", tint.Err(err))
+			return
+		}
+	}
+	slog.InfoContext(ctx, "evicted documents", slog.Int("count", evicted), slog.Int64("size", size))
+}
+
+// evictDocument deletes the document and sends the delete event to its webhooks.
+// Documents in the trash already sent the delete event, so only their webhooks are removed with them.
+func (s *Server) evictDocument(ctx context.Context, candidate database.DocumentSize) error {
+	if candidate.Deleted {
+		return s.db.PurgeDocument(ctx, candidate.ID)
+	}
+	document, err := s.db.DeleteDocument(ctx, candidate.ID)
+	if err != nil {
+		return err
+	}
+	s.ExecuteWebhooks(ctx, WebhookEventDelete, newWebhookDocument(*document))
+	return nil
 }
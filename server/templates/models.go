@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
 
 	"github.com/a-h/templ"
 )
@@ -96,6 +98,8 @@ type DocumentVersion struct {
 	Version int64
 	Label   string
 	Time    string
+	Message string
+	Author  string
 }
 
 type Style struct {
@@ -109,3 +113,88 @@ type ErrorVars struct {
 	Path      string
 	RequestID string
 }
+
+type CompareVars struct {
+	ID       string
+	From     int64
+	To       int64
+	Files    []CompareFile
+	Versions []DocumentVersion
+
+	Style string
+	Theme string
+}
+
+func (v CompareVars) ThemeCSSURL() string {
+	return fmt.Sprintf("/assets/theme.css?style=%s", v.Style)
+}
+
+func (v CompareVars) DocumentURL() string {
+	return fmt.Sprintf("/%s/%d", v.ID, v.To)
+}
+
+type CompareFile struct {
+	Name    string
+	OldName string
+	Status  string
+	Rows    []CompareRow
+}
+
+func (f CompareFile) Title() string {
+	if f.OldName != "" {
+		return f.OldName + " → " + f.Name
+	}
+	return f.Name
+}
+
+// CompareRow is a single row of the side by side view, a row with a Hunk header separates the hunks of a file.
+type CompareRow struct {
+	Hunk string
+	Old  CompareLine
+	New  CompareLine
+}
+
+// CompareLine is one side of a CompareRow, a Number of 0 means the line does not exist on this side.
+type CompareLine struct {
+	Number int
+	Op     string
+	HTML   string
+}
+
+func (l CompareLine) NumberString() string {
+	if l.Number == 0 {
+		return ""
+	}
+	return strconv.Itoa(l.Number)
+}
+
+type BlameVars struct {
+	ID        string
+	FileName  string
+	Lines     []BlameLine
+	Truncated bool
+
+	Style string
+	Theme string
+}
+
+func (v BlameVars) ThemeCSSURL() string {
+	return fmt.Sprintf("/assets/theme.css?style=%s", v.Style)
+}
+
+func (v BlameVars) DocumentURL() string {
+	return fmt.Sprintf("/%s?file=%s", v.ID, url.QueryEscape(v.FileName))
+}
+
+// CompareURL links to the changes of the version compared to the version before.
+func (v BlameVars) CompareURL(version int64) string {
+	return fmt.Sprintf("/%s/compare/...%d", v.ID, version)
+}
+
+// BlameLine is a line of a file with the version which introduced it, First is set on the first line of a run of lines from the same version.
+type BlameLine struct {
+	Number  int
+	Version DocumentVersion
+	First   bool
+	HTML    string
+}
@@ -66,6 +66,8 @@ type (
 		Key     string                `json:"key"`
 		Version int64                 `json:"version"`
 		Files   []WebhookDocumentFile `json:"files"`
+		// Trashed is true if the document has been moved into the trash and can still be restored.
+		Trashed bool `json:"trashed,omitempty"`
 	}
 
 	WebhookDocumentFile struct {
@@ -81,6 +83,23 @@ const (
 	WebhookEventDelete string = "delete"
 )
 
+func newWebhookDocument(document database.Document) WebhookDocument {
+	files := make([]WebhookDocumentFile, len(document.Files))
+	for i, file := range document.Files {
+		files[i] = WebhookDocumentFile{
+			Name:      file.Name,
+			Content:   file.Content,
+			Language:  file.Language,
+			ExpiresAt: file.ExpiresAt,
+		}
+	}
+	return WebhookDocument{
+		Key:     document.ID,
+		Version: document.Version,
+		Files:   files,
+	}
+}
+
 func (s *Server) ExecuteWebhooks(ctx context.Context, event string, document WebhookDocument) {
 	if s.cfg.Webhook == nil {
 		return
@@ -106,7 +125,8 @@ func (s *Server) executeWebhooks(ctx context.Context, event string, document Web
 		webhooks []database.Webhook
 		err      error
 	)
-	if event == WebhookEventDelete {
+	// webhooks of trashed documents are kept until the document is purged, so they still work after a restore
+	if event == WebhookEventDelete && !document.Trashed {
 		webhooks, err = s.db.GetAndDeleteWebhooksByDocumentID(dbCtx, document.Key)
 	} else {
 		webhooks, err = s.db.GetWebhooksByDocumentID(dbCtx, document.Key)